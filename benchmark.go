@@ -6,6 +6,7 @@ import (
 	"gonum.org/v1/gonum/stat"
 	"os"
 	"recommend-sys/core"
+	"runtime"
 	"time"
 )
 
@@ -21,7 +22,7 @@ func main() {
 		"Random":        core.NewRandom(nil),
 		"Baseline":      core.NewBaseLine(nil),
 		"SVD":           core.NewSVD(nil),
-		"SVD++":         core.NewSVD(nil),
+		"SVD++":         core.NewSVDpp(nil),
 		"NMF":           core.NewNMF(nil),
 		"Slope One":     core.NewSlopeOne(nil),
 		"KNN":           core.NewKNN(nil),
@@ -40,7 +41,7 @@ func main() {
 	for name, algo := range estimators {
 		start = time.Now()
 		out := core.CrossValidate(algo, set, []core.Evaluator{core.RMSE, core.MAE},
-			5, 0, nil)
+			core.NewKFoldSplitter(5, 0), nil, runtime.NumCPU())
 		tm := time.Since(start)
 		table.Append([]string{name,
 			fmt.Sprintf("%.6f", stat.Mean(out[0].Tests, nil)),
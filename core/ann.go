@@ -0,0 +1,274 @@
+package core
+
+import (
+	"gonum.org/v1/gonum/floats"
+	"math"
+	"math/rand"
+)
+
+const (
+	dotMetric    = "dot"
+	cosineMetric = "cosine"
+)
+
+// FactorModel is an estimator with latent factors of users and items.
+// Factors are indexed by inner IDs of the train set.
+type FactorModel interface {
+	Estimator
+	GetUserFactors() [][]float64
+	GetItemFactors() [][]float64
+	// GetUserFactor returns the factors of a user by the inner ID without
+	// building factors of other users.
+	GetUserFactor(innerUserID int) []float64
+}
+
+// LSHIndex is an approximate nearest neighbor index over vectors by random
+// projection LSH (Charikar). Each of nTables hash tables hashes a vector by
+// the signs of its projections onto nBits random hyperplanes. Buckets of a
+// query and buckets within Hamming distance 1 (multi-probe) are searched in
+// each table, and candidates are ranked by exact scores.
+//
+// For cosine similarity, hashes only depend on directions of vectors. For
+// inner product, vectors are transformed to the unit sphere (Neyshabur and Srebro)
+//
+//	x' = [x/M, sqrt(1 - |x|^2/M^2)],	q' = [q/|q|, 0]
+//
+// where M is the max norm of indexed vectors, so that the maximum inner
+// product search becomes a cosine search. Indexes of factors compute M over
+// all factors before hashing them, and all hash tables are rebuilt only if a
+// vector added later exceeds M.
+type LSHIndex struct {
+	Metric  string             // "dot" or "cosine"
+	Planes  [][][]float64      // table -> bit -> random hyperplane
+	Tables  []map[uint64][]int // table -> hash -> positions of vectors
+	Vectors [][]float64        // Indexed vectors
+	IDs     []int              // position -> ID
+	Index   map[int]int        // ID -> position
+	MaxNorm float64            // M
+}
+
+// NewLSHIndex creates an empty index of vectors of a dimension.
+// Parameters:
+//
+//	nTables	- The number of hash tables. Default is 10.
+//	nBits		- The number of hyperplanes of each table. Default is 8.
+//	seed		- The random seed of hyperplanes. Default is 0.
+func NewLSHIndex(dim int, metric string, params Parameters) *LSHIndex {
+	nTables := params.GetInt("nTables", 10)
+	nBits := params.GetInt("nBits", 8)
+	seed := int64(params.GetInt("seed", 0))
+	if metric != dotMetric && metric != cosineMetric {
+		panic("unknown metric: " + metric)
+	}
+	rng := rand.New(rand.NewSource(seed))
+	index := new(LSHIndex)
+	index.Metric = metric
+	index.Planes = make([][][]float64, nTables)
+	for i := range index.Planes {
+		index.Planes[i] = make([][]float64, nBits)
+		for j := range index.Planes[i] {
+			index.Planes[i][j] = newNormalVector(rng, dim+1, 0, 1)
+		}
+	}
+	index.Tables = make([]map[uint64][]int, nTables)
+	for i := range index.Tables {
+		index.Tables[i] = make(map[uint64][]int)
+	}
+	index.Index = make(map[int]int)
+	return index
+}
+
+// NewItemIndex indexes item factors of a factor model by outer item IDs.
+func NewItemIndex(model FactorModel, metric string, params Parameters) *LSHIndex {
+	trainSet := model.GetTrainSet()
+	return newFactorIndex(model.GetItemFactors(), trainSet.OuterItemIDs, metric, params)
+}
+
+// NewUserIndex indexes user factors of a factor model by outer user IDs.
+func NewUserIndex(model FactorModel, metric string, params Parameters) *LSHIndex {
+	trainSet := model.GetTrainSet()
+	return newFactorIndex(model.GetUserFactors(), trainSet.OuterUserIDs, metric, params)
+}
+
+func newFactorIndex(factors [][]float64, ids []int, metric string, params Parameters) *LSHIndex {
+	dim := 0
+	if len(factors) > 0 {
+		dim = len(factors[0])
+	}
+	index := NewLSHIndex(dim, metric, params)
+	// 先计算 M，避免逐个插入时反复重建哈希表
+	if metric == dotMetric {
+		for _, factor := range factors {
+			index.MaxNorm = math.Max(index.MaxNorm, floats.Norm(factor, 2))
+		}
+	}
+	for i, factor := range factors {
+		index.Add(ids[i], factor)
+	}
+	return index
+}
+
+// Len returns the number of indexed vectors.
+func (index *LSHIndex) Len() int {
+	return len(index.Vectors)
+}
+
+// Add a vector to the index. The vector of an existing ID is replaced.
+func (index *LSHIndex) Add(id int, vector []float64) {
+	vector = append([]float64(nil), vector...)
+	if position, exist := index.Index[id]; exist {
+		index.remove(position)
+		index.Vectors[position] = vector
+	} else {
+		index.Index[id] = len(index.Vectors)
+		index.IDs = append(index.IDs, id)
+		index.Vectors = append(index.Vectors, vector)
+	}
+	// 范数超过 M 时重建哈希表
+	if norm := floats.Norm(vector, 2); index.Metric == dotMetric && norm > index.MaxNorm {
+		index.MaxNorm = norm
+		for i := range index.Tables {
+			index.Tables[i] = make(map[uint64][]int)
+		}
+		for position := range index.Vectors {
+			index.insert(position)
+		}
+		return
+	}
+	index.insert(index.Index[id])
+}
+
+// Search the top n vectors for a query, in descending order of scores.
+func (index *LSHIndex) Search(query []float64, n int) []IDScore {
+	return index.search(query, n, -1)
+}
+
+// SearchByID searches the top n vectors for the vector of an indexed ID,
+// excluding the ID itself. Nil is returned if the ID is not indexed.
+func (index *LSHIndex) SearchByID(id int, n int) []IDScore {
+	position, exist := index.Index[id]
+	if !exist {
+		return nil
+	}
+	return index.search(index.Vectors[position], n, position)
+}
+
+func (index *LSHIndex) search(query []float64, n int, exclude int) []IDScore {
+	transformed := index.transformQuery(query)
+	visited := make(map[int]bool)
+	scores := make([]IDScore, 0)
+	for i, planes := range index.Planes {
+		hash := index.hash(planes, transformed)
+		// 探测汉明距离不超过 1 的桶
+		for bit := -1; bit < len(planes); bit++ {
+			probe := hash
+			if bit >= 0 {
+				probe ^= 1 << uint(bit)
+			}
+			for _, position := range index.Tables[i][probe] {
+				if position == exclude || visited[position] {
+					continue
+				}
+				visited[position] = true
+				scores = append(scores, IDScore{index.IDs[position], index.score(query, index.Vectors[position])})
+			}
+		}
+	}
+	return topIDScores(scores, n)
+}
+
+// score computes the exact score between a query and a vector.
+func (index *LSHIndex) score(query, vector []float64) float64 {
+	dot := floats.Dot(query, vector)
+	if index.Metric == cosineMetric {
+		return dot / (floats.Norm(query, 2) * floats.Norm(vector, 2))
+	}
+	return dot
+}
+
+func (index *LSHIndex) insert(position int) {
+	transformed := index.transform(index.Vectors[position])
+	for i, planes := range index.Planes {
+		hash := index.hash(planes, transformed)
+		index.Tables[i][hash] = append(index.Tables[i][hash], position)
+	}
+}
+
+func (index *LSHIndex) remove(position int) {
+	transformed := index.transform(index.Vectors[position])
+	for i, planes := range index.Planes {
+		hash := index.hash(planes, transformed)
+		bucket := index.Tables[i][hash]
+		for j := range bucket {
+			if bucket[j] == position {
+				index.Tables[i][hash] = append(bucket[:j], bucket[j+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// transform maps an indexed vector to the unit sphere of dimension d+1.
+func (index *LSHIndex) transform(vector []float64) []float64 {
+	ret := make([]float64, len(vector)+1)
+	copy(ret, vector)
+	if index.Metric == cosineMetric {
+		return ret
+	}
+	if index.MaxNorm > 0 {
+		floats.Scale(1/index.MaxNorm, ret)
+	}
+	ret[len(vector)] = math.Sqrt(math.Max(0, 1-floats.Dot(ret, ret)))
+	return ret
+}
+
+// transformQuery maps a query to the unit sphere of dimension d+1. Signs of
+// projections don't depend on the norm, so the query isn't normalized.
+func (index *LSHIndex) transformQuery(query []float64) []float64 {
+	ret := make([]float64, len(query)+1)
+	copy(ret, query)
+	return ret
+}
+
+func (index *LSHIndex) hash(planes [][]float64, vector []float64) uint64 {
+	var hash uint64
+	for bit, plane := range planes {
+		if floats.Dot(plane, vector) >= 0 {
+			hash |= 1 << uint(bit)
+		}
+	}
+	return hash
+}
+
+// RecommendByIndex ranks items for a user by inner products (or cosine
+// similarities) between the user factors and item factors in an item index.
+// Biases of the model are ignored. Items rated by the user in the training
+// set are excluded, as Recommend does by default. Nil is returned for
+// unknown users.
+func RecommendByIndex(model FactorModel, index *LSHIndex, userId, n int) []IDScore {
+	trainSet := model.GetTrainSet()
+	innerUserId := trainSet.ConvertUserID(userId)
+	if innerUserId == newID {
+		return nil
+	}
+	rated := make(Set)
+	for _, ir := range trainSet.UserRatings()[innerUserId] {
+		rated[trainSet.OuterItemIDs[ir.ID]] = nil
+	}
+	// 多检索已评分物品的数量，过滤后保留前 n 个
+	m := n
+	if n > 0 {
+		m += len(rated)
+	}
+	items := index.Search(model.GetUserFactor(innerUserId), m)
+	ret := make([]IDScore, 0, len(items))
+	for _, item := range items {
+		if _, exist := rated[item.ID]; !exist {
+			ret = append(ret, item)
+		}
+	}
+	if n > 0 && n < len(ret) {
+		ret = ret[:n]
+	}
+	return ret
+}
@@ -0,0 +1,174 @@
+package core
+
+import (
+	"math"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// bruteForce searches the top n vectors by scanning all vectors.
+func bruteForce(index *LSHIndex, query []float64, n int, exclude int) map[int]bool {
+	scores := make([]IDScore, 0, index.Len())
+	for position, vector := range index.Vectors {
+		if position != exclude {
+			scores = append(scores, IDScore{index.IDs[position], index.score(query, vector)})
+		}
+	}
+	sort.Sort(sortedIDScores(scores))
+	ret := make(map[int]bool)
+	for _, score := range scores[:n] {
+		ret[score.ID] = true
+	}
+	return ret
+}
+
+func testLSHRecall(t *testing.T, metric string, minRecall float64) {
+	model := NewSVD(Parameters{"seed": 1})
+	model.Fit(NewTrainSet(LoadDataFromBuiltIn("ml-100k")))
+	index := NewItemIndex(model, metric, Parameters{"nTables": 30})
+	hit, total, candidates := 0, 0, 0
+	for position := 0; position < index.Len(); position += 10 {
+		expected := bruteForce(index, index.Vectors[position], 10, position)
+		for _, score := range index.SearchByID(index.IDs[position], 10) {
+			if expected[score.ID] {
+				hit++
+			}
+		}
+		total += len(expected)
+		candidates += len(index.SearchByID(index.IDs[position], 0))
+	}
+	// 候选集应小于全部向量
+	if candidates >= total/10*(index.Len()-1) {
+		t.Fatalf("%s search scans all vectors", metric)
+	}
+	if recall := float64(hit) / float64(total); recall < minRecall {
+		t.Fatalf("recall@10 of %s search %.3f < %.3f", metric, recall, minRecall)
+	}
+}
+
+func TestLSHIndexCosine(t *testing.T) {
+	testLSHRecall(t, cosineMetric, 0.9)
+}
+
+func TestLSHIndexDot(t *testing.T) {
+	testLSHRecall(t, dotMetric, 0.8)
+}
+
+func TestLSHIndexAdd(t *testing.T) {
+	index := NewLSHIndex(2, dotMetric, Parameters{"nTables": 4, "nBits": 2})
+	index.Add(1, []float64{1, 0})
+	index.Add(2, []float64{0, 1})
+	// 范数增大时重建哈希表
+	index.Add(3, []float64{2, 2})
+	if index.Len() != 3 {
+		t.Fatalf("expect 3 vectors, got %d", index.Len())
+	}
+	if top := index.Search([]float64{1, 1}, 1); top[0].ID != 3 {
+		t.Fatalf("expect top-1 is 3, got %v", top)
+	}
+	// 替换已有向量
+	index.Add(3, []float64{-1, -1})
+	if index.Len() != 3 {
+		t.Fatalf("expect 3 vectors, got %d", index.Len())
+	}
+	if top := index.Search([]float64{1, 1}, 1); top[0].ID == 3 {
+		t.Fatalf("expect top-1 isn't 3, got %v", top)
+	}
+	if ret := index.SearchByID(4, 1); ret != nil {
+		t.Fatalf("expect nil for unknown ID, got %v", ret)
+	}
+}
+
+func TestNewFactorIndex(t *testing.T) {
+	// 范数递增的向量
+	factors := [][]float64{{1, 0}, {0, 2}, {3, 0}, {0, 4}}
+	index := newFactorIndex(factors, []int{1, 2, 3, 4}, dotMetric, Parameters{"nTables": 4, "nBits": 2})
+	if index.MaxNorm != 4 {
+		t.Fatalf("expect max norm is %v, got %v", 4.0, index.MaxNorm)
+	}
+	// 每个向量在每个哈希表中只出现一次
+	for i, table := range index.Tables {
+		count := 0
+		for _, bucket := range table {
+			count += len(bucket)
+		}
+		if count != len(factors) {
+			t.Fatalf("expect %d vectors in table %d, got %d", len(factors), i, count)
+		}
+	}
+	if top := index.Search([]float64{0, 1}, 1); top[0].ID != 4 {
+		t.Fatalf("expect top-1 is 4, got %v", top)
+	}
+}
+
+func TestLSHIndexSave(t *testing.T) {
+	model := NewSVD(Parameters{"seed": 1})
+	model.Fit(NewTrainSet(LoadDataFromBuiltIn("ml-100k")))
+	index1 := NewItemIndex(model, dotMetric, nil)
+	if err := Save(filepath.Join(tempDir, "/lsh.m"), index1); err != nil {
+		t.Fatal(err)
+	}
+	index2 := new(LSHIndex)
+	if err := Load(filepath.Join(tempDir, "/lsh.m"), index2); err != nil {
+		t.Fatal(err)
+	}
+	top1, top2 := index1.SearchByID(1, 10), index2.SearchByID(1, 10)
+	if len(top1) != len(top2) {
+		t.Fatalf("the restored index returns %d results, expect %d", len(top2), len(top1))
+	}
+	for i := range top1 {
+		if top1[i] != top2[i] {
+			t.Fatalf("the restored index returns %v, expect %v", top2, top1)
+		}
+	}
+	// 恢复后仍可插入
+	index2.Add(-1, model.GetItemFactors()[0])
+	if index2.Len() != index1.Len()+1 {
+		t.Fatalf("expect %d vectors, got %d", index1.Len()+1, index2.Len())
+	}
+}
+
+func TestRecommendByIndex(t *testing.T) {
+	model := NewSVD(Parameters{"seed": 1})
+	trainSet := NewTrainSet(LoadDataFromBuiltIn("ml-100k"))
+	model.Fit(trainSet)
+	index := NewItemIndex(model, dotMetric, nil)
+	items := RecommendByIndex(model, index, 1, 10)
+	if len(items) != 10 {
+		t.Fatalf("expect 10 items, got %d", len(items))
+	}
+	innerUserId := trainSet.ConvertUserID(1)
+	userFactor := model.GetUserFactors()[innerUserId]
+	for _, item := range items {
+		expected := floats.Dot(userFactor, model.GetItemFactors()[trainSet.ConvertItemID(item.ID)])
+		if math.Abs(expected-item.Score) > 1e-9 {
+			t.Fatalf("expect score %v, got %v", expected, item.Score)
+		}
+		// 已评分物品被排除
+		for _, ir := range trainSet.UserRatings()[innerUserId] {
+			if trainSet.OuterItemIDs[ir.ID] == item.ID {
+				t.Fatalf("expect rated item %d is excluded", item.ID)
+			}
+		}
+	}
+	if ret := RecommendByIndex(model, index, -1, 10); ret != nil {
+		t.Fatalf("expect nil for unknown user, got %v", ret)
+	}
+}
+
+func TestGetUserFactor(t *testing.T) {
+	trainSet := NewTrainSet(LoadDataFromBuiltIn("ml-100k"))
+	for _, model := range []FactorModel{NewSVD(nil), NewSVDpp(Parameters{"nEpochs": 1})} {
+		model.Fit(trainSet)
+		userFactors := model.GetUserFactors()
+		for _, innerUserId := range []int{0, trainSet.UserCount - 1} {
+			if !floats.Equal(model.GetUserFactor(innerUserId), userFactors[innerUserId]) {
+				t.Fatalf("%T: expect user factor %v, got %v", model,
+					userFactors[innerUserId], model.GetUserFactor(innerUserId))
+			}
+		}
+	}
+}
@@ -3,12 +3,14 @@ package core
 import (
 	"gonum.org/v1/gonum/stat"
 	"math/rand"
+	"sync"
 )
 
 type Estimator interface {
 	SetParams(params Parameters)
 	Predict(userId, itemId int) float64
 	Fit(trainSet TrainSet)
+	GetTrainSet() TrainSet
 }
 
 type Parameters map[string]interface{}
@@ -44,11 +46,51 @@ func (parameters Parameters) GetFloat64(name string, _default float64) float64 {
 
 func (parameters Parameters) GetSim(name string, _default Sim) Sim {
 	if val, exist := parameters[name]; exist {
+		if sim, ok := val.(func(SortedIdRatings, SortedIdRatings) float64); ok {
+			return sim
+		}
 		return val.(Sim)
 	}
 	return _default
 }
 
+// GetSimBuilder gets a SimBuilder, or a Sim wrapped by NewSimBuilder.
+func (parameters Parameters) GetSimBuilder(name string, _default SimBuilder) SimBuilder {
+	if val, exist := parameters[name]; exist {
+		switch builder := val.(type) {
+		case SimBuilder:
+			return builder
+		case func(TrainSet, bool) ([]SortedIdRatings, Sim):
+			return builder
+		}
+		return NewSimBuilder(parameters.GetSim(name, nil))
+	}
+	return _default
+}
+
+func (parameters Parameters) GetSideInfo(name string, _default *SideInfo) *SideInfo {
+	if val, exist := parameters[name]; exist {
+		return val.(*SideInfo)
+	}
+	return _default
+}
+
+// Callback reports the training progress of an estimator. It is called
+// with the number of finished epochs and the total number of epochs.
+type Callback func(epoch, nEpochs int)
+
+func (parameters Parameters) GetCallback(name string, _default Callback) Callback {
+	if val, exist := parameters[name]; exist {
+		switch callback := val.(type) {
+		case Callback:
+			return callback
+		case func(int, int):
+			return callback
+		}
+	}
+	return _default
+}
+
 func (parameters Parameters) GetString(name string, _default string) string {
 	if val, exist := parameters[name]; exist {
 		return val.(string)
@@ -65,6 +107,11 @@ func (base *Base) SetParams(params Parameters) {
 	base.Params = params
 }
 
+// GetTrainSet returns the training data of the last Fit.
+func (base *Base) GetTrainSet() TrainSet {
+	return base.Data
+}
+
 func (base *Base) Predict(userId, itemId int) float64 {
 	panic("Predict() not implemented")
 }
@@ -79,6 +126,9 @@ type Random struct {
 	StdDev float64 // sigma
 	Low    float64 // The lower bound of rating scores
 	High   float64 // The upper bound of rating scores
+	Seed   int64   // The random seed of predictions
+	rng    *rand.Rand
+	mutex  sync.Mutex
 }
 
 func NewRandom(params Parameters) *Random {
@@ -87,8 +137,15 @@ func NewRandom(params Parameters) *Random {
 	return random
 }
 
+// Predict a random rating. The generator is shared by goroutines, and it is
+// recreated from the seed if the model is restored by Load.
 func (random *Random) Predict(userId int, itemId int) float64 {
-	ret := rand.NormFloat64()*random.StdDev + random.Mean
+	random.mutex.Lock()
+	if random.rng == nil {
+		random.rng = rand.New(rand.NewSource(random.Seed))
+	}
+	ret := random.rng.NormFloat64()*random.StdDev + random.Mean
+	random.mutex.Unlock()
 	// Crop prediction
 	if ret < random.Low {
 		ret = random.Low
@@ -98,8 +155,15 @@ func (random *Random) Predict(userId int, itemId int) float64 {
 	return ret
 }
 
+// Fit a random model.
+// Parameters:
+//
+//	seed		- The random seed of predictions. Default is 0.
 func (random *Random) Fit(trainSet TrainSet) {
+	random.Seed = int64(random.Params.GetInt("seed", 0))
+	random.rng = rand.New(rand.NewSource(random.Seed))
 	ratings := trainSet.Ratings
+	random.Data = trainSet
 	random.Mean = trainSet.GlobalMean
 	random.StdDev = stat.StdDev(ratings, nil)
 	random.Low, random.High = trainSet.RatingRange()
@@ -110,7 +174,6 @@ type BaseLine struct {
 	userBias   []float64 // b_u
 	itemBias   []float64 // b_i
 	globalBias float64   // mu
-	trainSet   TrainSet
 }
 
 func NewBaseLine(params Parameters) *BaseLine {
@@ -121,8 +184,8 @@ func NewBaseLine(params Parameters) *BaseLine {
 
 func (baseLine *BaseLine) Predict(userId, itemId int) float64 {
 	// Convert to inner Id
-	innerUserId := baseLine.trainSet.ConvertUserID(userId)
-	innerItemId := baseLine.trainSet.ConvertItemID(itemId)
+	innerUserId := baseLine.Data.ConvertUserID(userId)
+	innerItemId := baseLine.Data.ConvertItemID(itemId)
 	ret := baseLine.globalBias
 	if innerUserId != newID {
 		ret += baseLine.userBias[innerUserId]
@@ -132,18 +195,42 @@ func (baseLine *BaseLine) Predict(userId, itemId int) float64 {
 	}
 	return ret
 }
+
+// Fit a baseline model. Ratings are shuffled in each epoch and could be
+// trained in parallel like SVD. The global bias is fixed to the global mean
+// by DSGD with more than one goroutine.
+// Parameters:
+//
+//	 reg 		- The regularization parameter of the cost function that is
+//				  optimized. Default is 0.02.
+//	 lr 		- The learning rate of SGD. Default is 0.005.
+//	 nEpochs	- The number of iteration of the SGD procedure. Default is 20.
+//	 parallel	- The parallel SGD, "hogwild" or "dsgd". Default is "hogwild".
+//	 nJobs		- The number of goroutines. Default is 1.
+//	 seed		- The random seed of shuffling. Default is 0.
 func (baseLine *BaseLine) Fit(trainSet TrainSet) {
 	// Setup parameters
 	reg := baseLine.Params.GetFloat64("reg", 0.02)
 	lr := baseLine.Params.GetFloat64("lr", 0.005)
 	nEpochs := baseLine.Params.GetInt("nEpochs", 20)
+	parallel := baseLine.Params.GetString("parallel", hogwildSGD)
+	nJobs := max(1, baseLine.Params.GetInt("nJobs", 1))
+	seed := int64(baseLine.Params.GetInt("seed", 0))
+	rng := rand.New(rand.NewSource(seed))
 	// Initialize parameters
-	baseLine.trainSet = trainSet
+	baseLine.Data = trainSet
+	baseLine.globalBias = 0
 	baseLine.userBias = make([]float64, trainSet.UserCount)
 	baseLine.itemBias = make([]float64, trainSet.ItemCount)
+	schedule := newSGDSchedule(trainSet, parallel, nJobs)
+	// Fix the global bias to the global mean if it couldn't be updated in parallel
+	if !schedule.updateShared() {
+		baseLine.globalBias = trainSet.GlobalMean
+	}
 	// Stochastic Gradient Descent
 	for epoch := 0; epoch < nEpochs; epoch++ {
-		for i := 0; i < trainSet.Length(); i++ {
+		schedule.shuffle(rng)
+		schedule.run(func(jobID, i int) {
 			userId, itemId, rating := trainSet.Users[i], trainSet.Items[i], trainSet.Ratings[i]
 			innerUserId := trainSet.ConvertUserID(userId)
 			innerItemId := trainSet.ConvertItemID(itemId)
@@ -155,9 +242,11 @@ func (baseLine *BaseLine) Fit(trainSet TrainSet) {
 			gradUserBias := diff + reg*userBias
 			gradItemBias := diff + reg*itemBias
 			// Update parameters
-			baseLine.globalBias -= lr * gradGlobalBias
+			if schedule.updateShared() {
+				baseLine.globalBias -= lr * gradGlobalBias
+			}
 			baseLine.userBias[innerUserId] -= lr * gradUserBias
 			baseLine.itemBias[innerItemId] -= lr * gradItemBias
-		}
+		})
 	}
 }
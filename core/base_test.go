@@ -1,16 +1,25 @@
 package core
 
 import (
+	"gonum.org/v1/gonum/floats"
 	"gonum.org/v1/gonum/stat"
+	"runtime"
+	"sort"
 	"testing"
 )
 
 const estimatorEpsilon float64 = 0.008
 
 func Evaluate(t *testing.T, algo Estimator, dataSet DataSet,
+	expectRMSE float64, expectMAE float64) {
+	EvaluateWithParams(t, algo, dataSet, nil, expectRMSE, expectMAE)
+}
+
+// EvaluateWithParams checks RMSE and MAE of an estimator with parameters.
+func EvaluateWithParams(t *testing.T, algo Estimator, dataSet DataSet, params Parameters,
 	expectRMSE float64, expectMAE float64) {
 	// Cross validation
-	results := CrossValidate(algo, dataSet, []Evaluator{RMSE, MAE}, 5, 0, nil)
+	results := CrossValidate(algo, dataSet, []Evaluator{RMSE, MAE}, NewKFoldSplitter(5, 0), params, runtime.NumCPU())
 	// Check RMSE
 	rmse := stat.Mean(results[0].Tests, nil)
 	if rmse > expectRMSE+estimatorEpsilon {
@@ -23,6 +32,24 @@ func Evaluate(t *testing.T, algo Estimator, dataSet DataSet,
 	}
 }
 
+// EvaluateRanking checks the sampled AUC of an estimator trained on implicit feedback.
+func EvaluateRanking(t *testing.T, algo Estimator, dataSet DataSet, expectAUC float64) {
+	// Cross validation
+	results := CrossValidate(algo, dataSet, []Evaluator{NewSampledAUC(dataSet, 100, 0)},
+		NewKFoldSplitter(5, 0), nil, runtime.NumCPU())
+	// Check AUC
+	auc := stat.Mean(results[0].Tests, nil)
+	if auc < expectAUC-estimatorEpsilon {
+		t.Fatalf("AUC(%.3f) < %.3f-%.3f", auc, expectAUC, estimatorEpsilon)
+	}
+}
+
+// LoadImplicitDataFromBuiltIn loads ratings not less than 4 as implicit feedback.
+func LoadImplicitDataFromBuiltIn(dataSetName string) DataSet {
+	dataSet := LoadDataFromBuiltIn(dataSetName)
+	return dataSet.ToImplicit(4)
+}
+
 func TestRandom(t *testing.T) {
 	Evaluate(t, NewRandom(nil), LoadDataFromBuiltIn("ml-100k"), 1.514, 1.215)
 }
@@ -35,9 +62,9 @@ func TestSVD(t *testing.T) {
 	Evaluate(t, NewSVD(nil), LoadDataFromBuiltIn("ml-100k"), 0.934, 0.737)
 }
 
-//func TestSVDPP(t *testing.T) {
-//	Evaluate(t, NewSVDpp(nil), LoadDataFromBuiltIn("ml-100k"), 0.92, 0.722)
-//}
+func TestSVDPP(t *testing.T) {
+	Evaluate(t, NewSVDpp(nil), LoadDataFromBuiltIn("ml-100k"), 0.92, 0.722)
+}
 
 func TestNMF(t *testing.T) {
 	Evaluate(t, NewNMF(nil), LoadDataFromBuiltIn("ml-100k"), 0.963, 0.758)
@@ -66,3 +93,266 @@ func TestKNNBaseLine(t *testing.T) {
 func TestCoClustering(t *testing.T) {
 	Evaluate(t, NewCoClustering(nil), LoadDataFromBuiltIn("ml-100k"), 0.963, 0.753)
 }
+
+func TestALS(t *testing.T) {
+	EvaluateRanking(t, NewALS(nil), LoadImplicitDataFromBuiltIn("ml-100k"), 0.91)
+}
+
+func TestALSEmpty(t *testing.T) {
+	als := NewALS(nil)
+	als.Fit(NewTrainSet(NewRawSet([]int{}, []int{}, []float64{})))
+	if prediction := als.Predict(1, 1); prediction != 0 {
+		t.Fatalf("Prediction (%.3f) != %.3f", prediction, 0.0)
+	}
+}
+
+func TestALSSolve(t *testing.T) {
+	// A = diag(0.5, -0.5) isn't positive definite, b = (2, 0)
+	dst := [][]float64{{9, 9}}
+	fixed := [][]float64{{1, 0}, {0, 1}}
+	ratings := [][]IDRating{{{0, 1}}}
+	confidence := func(float64) float64 { return 2 }
+	alsSolve(dst, fixed, ratings, 2, -1.5, confidence, 1)
+	if !floats.EqualApprox(dst[0], []float64{4, 0}, 1e-9) {
+		t.Fatalf("Solution %v != %v", dst[0], []float64{4, 0})
+	}
+	// Singular A
+	alsSolve(dst, fixed, ratings, 2, -2, confidence, 1)
+	if !floats.Equal(dst[0], []float64{0, 0}) {
+		t.Fatalf("Solution %v != %v", dst[0], []float64{0, 0})
+	}
+}
+
+func TestBPR(t *testing.T) {
+	EvaluateRanking(t, NewBPR(nil), LoadImplicitDataFromBuiltIn("ml-100k"), 0.92)
+}
+
+func TestBPREmpty(t *testing.T) {
+	bpr := NewBPR(nil)
+	bpr.Fit(NewTrainSet(NewRawSet([]int{}, []int{}, []float64{})))
+	if prediction := bpr.Predict(1, 1); prediction != 0 {
+		t.Fatalf("Prediction (%.3f) != %.3f", prediction, 0.0)
+	}
+}
+
+func TestWARP(t *testing.T) {
+	EvaluateRanking(t, NewWARP(nil), LoadImplicitDataFromBuiltIn("ml-100k"), 0.905)
+}
+
+func TestWARPEmpty(t *testing.T) {
+	warp := NewWARP(nil)
+	warp.Fit(NewTrainSet(NewRawSet([]int{}, []int{}, []float64{})))
+	if prediction := warp.Predict(1, 1); prediction != 0 {
+		t.Fatalf("Prediction (%.3f) != %.3f", prediction, 0.0)
+	}
+}
+
+func TestFM(t *testing.T) {
+	Evaluate(t, NewFM(nil), LoadDataFromBuiltIn("ml-100k"), 0.925, 0.727)
+}
+
+func TestTimeSVDPP(t *testing.T) {
+	Evaluate(t, NewTimeSVDpp(nil), LoadDataFromBuiltIn("ml-100k"), 0.913, 0.716)
+}
+
+func TestTimeSVDPPEmpty(t *testing.T) {
+	tpp := NewTimeSVDpp(nil)
+	tpp.Fit(NewTrainSet(NewRawSet([]int{}, []int{}, []float64{})))
+	if prediction := tpp.Predict(1, 1); prediction != 0 {
+		t.Fatalf("Prediction (%.3f) != %.3f", prediction, 0.0)
+	}
+}
+
+func TestSVDPPCallback(t *testing.T) {
+	epochs := make([]int, 0)
+	svdpp := NewSVDpp(Parameters{"nEpochs": 3, "callback": func(epoch, nEpochs int) {
+		epochs = append(epochs, epoch)
+	}})
+	svdpp.Fit(NewTrainSet(LoadDataFromBuiltIn("ml-100k")))
+	if len(epochs) != 3 || epochs[2] != 3 {
+		t.Fatalf("Callback epochs (%v) != (%v)", epochs, []int{1, 2, 3})
+	}
+}
+
+func TestGetCallback(t *testing.T) {
+	count := 0
+	params := Parameters{"plain": func(epoch, nEpochs int) { count++ }, "wrong": 1}
+	params.GetCallback("plain", nil)(1, 1)
+	params["typed"] = Callback(func(epoch, nEpochs int) { count++ })
+	params.GetCallback("typed", nil)(1, 1)
+	if count != 2 {
+		t.Fatalf("Number of calls (%d) != %d", count, 2)
+	}
+	// Values of other types fall back to the default
+	if params.GetCallback("wrong", nil) != nil {
+		t.Fatal("Callback of a wrong type is not nil")
+	}
+}
+
+func TestSVDHogwild(t *testing.T) {
+	params := Parameters{"parallel": "hogwild", "nJobs": 4}
+	EvaluateWithParams(t, NewSVD(nil), LoadDataFromBuiltIn("ml-100k"), params, 0.934, 0.737)
+}
+
+func TestSVDDSGD(t *testing.T) {
+	params := Parameters{"parallel": "dsgd", "nJobs": 4}
+	EvaluateWithParams(t, NewSVD(nil), LoadDataFromBuiltIn("ml-100k"), params, 0.934, 0.737)
+}
+
+func TestBaseLineDSGD(t *testing.T) {
+	params := Parameters{"parallel": "dsgd", "nJobs": 4}
+	EvaluateWithParams(t, NewBaseLine(nil), LoadDataFromBuiltIn("ml-100k"), params, 0.944, 0.748)
+}
+
+func TestSGDInvalidJobs(t *testing.T) {
+	trainSet := NewTrainSet(NewRawSet([]int{1, 1, 2}, []int{1, 2, 2}, []float64{5, 1, 2}))
+	for _, nJobs := range []int{0, -1} {
+		for _, parallel := range []string{hogwildSGD, dsgdSGD} {
+			params := Parameters{"parallel": parallel, "nJobs": nJobs}
+			// At least one goroutine is used
+			NewSVD(params).Fit(trainSet)
+			NewBaseLine(params).Fit(trainSet)
+		}
+	}
+}
+
+func TestReproducible(t *testing.T) {
+	dataSet := LoadDataFromBuiltIn("ml-100k")
+	trainSet, testSet := dataSet.Split(0.2, 0)
+	implicitDataSet := LoadImplicitDataFromBuiltIn("ml-100k")
+	implicitTrainSet, implicitTestSet := implicitDataSet.Split(0.2, 0)
+	params := Parameters{"seed": 1, "nEpochs": 2}
+	for _, newEstimator := range []func() Estimator{
+		func() Estimator { return NewRandom(params) },
+		func() Estimator { return NewBaseLine(params) },
+		func() Estimator { return NewSVD(params) },
+		func() Estimator { return NewNMF(params) },
+		func() Estimator { return NewSVDpp(params) },
+		func() Estimator { return NewTimeSVDpp(params) },
+		func() Estimator { return NewFM(params) },
+		func() Estimator { return NewCoClustering(params) },
+	} {
+		a, b := newEstimator(), newEstimator()
+		a.Fit(trainSet)
+		b.Fit(trainSet)
+		if !floats.Equal(testSet.Predict(a), testSet.Predict(b)) {
+			t.Fatalf("%T is not reproducible", a)
+		}
+	}
+	for _, newEstimator := range []func() Estimator{
+		func() Estimator { return NewALS(params) },
+		func() Estimator { return NewBPR(params) },
+		func() Estimator { return NewWARP(params) },
+	} {
+		a, b := newEstimator(), newEstimator()
+		a.Fit(implicitTrainSet)
+		b.Fit(implicitTrainSet)
+		if !floats.Equal(implicitTestSet.Predict(a), implicitTestSet.Predict(b)) {
+			t.Fatalf("%T is not reproducible", a)
+		}
+	}
+}
+
+func TestKNNNeighbors(t *testing.T) {
+	trainSet := NewTrainSet(LoadDataFromBuiltIn("ml-100k"))
+	itemRatings := trainSet.ItemRatings()
+	for _, params := range []Parameters{
+		{},
+		{"nNeighbors": 0},
+		{"nNeighbors": 100},
+		{"nNeighbors": 100, "maxMemory": 10 * neighborSize * trainSet.UserCount},
+		{"maxMemory": 10 * neighborSize * trainSet.UserCount},
+		{"maxMemory": 0},
+	} {
+		knn := NewKNN(params)
+		knn.Fit(trainSet)
+		expect := params.GetInt("nNeighbors", 0)
+		if maxMemory := params.GetInt("maxMemory", defaultMaxMemory); maxMemory > 0 {
+			limit := maxMemory / neighborSize / trainSet.UserCount
+			if expect <= 0 || limit < expect {
+				expect = limit
+			}
+		} else if expect <= 0 {
+			expect = trainSet.UserCount - 1
+		}
+		for _, neighbors := range knn.Neighbors {
+			if len(neighbors) > expect {
+				t.Fatalf("Number of neighbors (%d) > %d", len(neighbors), expect)
+			}
+			for i := 1; i < len(neighbors); i++ {
+				if neighbors[i-1].ID >= neighbors[i].ID {
+					t.Fatalf("Neighbors are not sorted by ID")
+				}
+			}
+		}
+	}
+	// Ratings of the train set are not sorted in place
+	freshSet := NewTrainSet(LoadDataFromBuiltIn("ml-100k"))
+	for i, irs := range freshSet.ItemRatings() {
+		for j := range irs {
+			if irs[j] != itemRatings[i][j] {
+				t.Fatal("Ratings of the train set are changed")
+			}
+		}
+	}
+}
+
+func TestKNNTopNeighbors(t *testing.T) {
+	params := Parameters{"nNeighbors": 300}
+	EvaluateWithParams(t, NewKNN(nil), LoadDataFromBuiltIn("ml-100k"), params, 0.98, 0.774)
+}
+
+func TestKNNSelectNeighbors(t *testing.T) {
+	trainSet := NewTrainSet(LoadDataFromBuiltIn("ml-100k"))
+	knn := NewKNN(Parameters{"nNeighbors": 100})
+	knn.Fit(trainSet)
+	for _, k := range []int{0, 1, 40, 1000} {
+		for userID := 0; userID < trainSet.UserCount; userID += 10 {
+			for itemID := 0; itemID < trainSet.ItemCount; itemID += 10 {
+				// Sort all merged neighbors as before
+				all := make([]float64, 0)
+				ptr, neighbors := 0, knn.Neighbors[userID]
+				for _, ir := range knn.RightRatings[itemID] {
+					for ptr < len(neighbors) && neighbors[ptr].ID < ir.ID {
+						ptr++
+					}
+					if ptr < len(neighbors) && neighbors[ptr].ID == ir.ID {
+						all = append(all, neighbors[ptr].Score)
+					}
+				}
+				sort.Sort(sort.Reverse(sort.Float64Slice(all)))
+				candidates, count := knn.topNeighbors(userID, itemID, k, false)
+				if count != len(all) {
+					t.Fatalf("Number of merged neighbors (%d) != %d", count, len(all))
+				}
+				if len(all) > k {
+					all = all[:k]
+				}
+				top := make([]float64, len(candidates))
+				for i := range candidates {
+					top[i] = candidates[i].Sim
+				}
+				sort.Sort(sort.Reverse(sort.Float64Slice(top)))
+				if !floats.Equal(top, all) {
+					t.Fatalf("Top neighbors %v != %v", top, all)
+				}
+			}
+		}
+	}
+}
+
+func TestKNNShrinkage(t *testing.T) {
+	params := Parameters{"minSupport": 5, "shrinkage": 100.0}
+	EvaluateWithParams(t, NewKNNWithMean(nil), LoadDataFromBuiltIn("ml-100k"), params, 0.951, 0.749)
+}
+
+func TestKNNPearsonBaseline(t *testing.T) {
+	// Signed similarities need positive neighbors
+	params := Parameters{"sim": SimBuilder(PearsonBaseline), "shrinkage": 100.0, "positiveOnly": true}
+	EvaluateWithParams(t, NewKNNBaseLine(nil), LoadDataFromBuiltIn("ml-100k"), params, 0.931, 0.733)
+}
+
+func TestKNNAdjustedCosine(t *testing.T) {
+	params := Parameters{"sim": SimBuilder(AdjustedCosine), "userBased": false, "shrinkage": 100.0, "positiveOnly": true}
+	EvaluateWithParams(t, NewKNNWithMean(nil), LoadDataFromBuiltIn("ml-100k"), params, 0.951, 0.749)
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"math"
+	"math/rand"
 
 	"gonum.org/v1/gonum/floats"
 )
@@ -16,13 +17,12 @@ type CoClustering struct {
 	userClusterMeans []float64   // 每个用户簇的平均评分
 	itemClusterMeans []float64   // 每个物品簇的平均评分
 	coClusterMeans   [][]float64 // 用户簇-物品簇的平均评分
-	trainSet         TrainSet    // 训练数据集
 }
 
 func (c *CoClustering) Predict(userId, itemId int) float64 {
 	// Convert to inner Id
-	innerUserId := c.trainSet.ConvertUserID(userId)
-	innerItemId := c.trainSet.ConvertItemID(itemId)
+	innerUserId := c.Data.ConvertUserID(userId)
+	innerItemId := c.Data.ConvertItemID(itemId)
 	prediction := 0.0
 	if innerUserId != newID && innerItemId != newID {
 		// old user - old item
@@ -50,22 +50,25 @@ func (c *CoClustering) Predict(userId, itemId int) float64 {
 //	nEpochs		- The number of iteration of the SGD procedure. Default is 20.
 //	nUserClusters	- The number of user clusters.
 //	nItemClusters	- The number of item clusters.
+//	seed		- The random seed of initial clusters. Default is 0.
 func (c *CoClustering) Fit(trainSet TrainSet) {
 	// Setup parameters
 	// 参数设定分， 用户与物品划分为三类
 	nUserClusters := c.Params.GetInt("nUserClusters", 3)
 	nItemClusters := c.Params.GetInt("nItemClusters", 3)
 	nEpochs := c.Params.GetInt("nEpochs", 20)
+	seed := int64(c.Params.GetInt("seed", 0))
+	rng := rand.New(rand.NewSource(seed))
 	// Initialize parameters
-	c.trainSet = trainSet
+	c.Data = trainSet
 	c.globalMean = trainSet.GlobalMean
 	// 用户对物品评分的平均值
 	c.userMeans = means(trainSet.UserRatings())
 	// 物品受到评分的平均值
 	c.itemMeans = means(trainSet.ItemRatings())
 	// 初始化用户与物品簇
-	c.userClusters = newUniformVectorInt(trainSet.UserCount, 0, nUserClusters)
-	c.itemClusters = newUniformVectorInt(trainSet.ItemCount, 0, nItemClusters)
+	c.userClusters = newUniformVectorInt(rng, trainSet.UserCount, 0, nUserClusters)
+	c.itemClusters = newUniformVectorInt(rng, trainSet.ItemCount, 0, nItemClusters)
 
 	c.userClusterMeans = make([]float64, nUserClusters)
 	c.itemClusterMeans = make([]float64, nItemClusters)
@@ -19,9 +19,14 @@ import (
 )
 
 type DataSet struct {
-	Ratings []float64
-	Users   []int
-	Items   []int
+	Ratings    []float64
+	Users      []int
+	Items      []int
+	Timestamps []int64       // Optional. Nil if the data set has no timestamps.
+	Contexts   []Features    // Optional. Context features of ratings (e.g. device or time of day).
+	UserDict   *IDDictionary // Optional. Raw string IDs of users.
+	ItemDict   *IDDictionary // Optional. Raw string IDs of items.
+	Implicit   bool          // Ratings are interaction counts (or weights) of implicit feedback.
 }
 
 func NewRawSet(users, items []int, ratings []float64) DataSet {
@@ -32,6 +37,23 @@ func NewRawSet(users, items []int, ratings []float64) DataSet {
 	}
 }
 
+// NewTimedRawSet creates a data set with timestamps.
+func NewTimedRawSet(users, items []int, ratings []float64, timestamps []int64) DataSet {
+	set := NewRawSet(users, items, ratings)
+	set.Timestamps = timestamps
+	return set
+}
+
+// HasTimestamps checks whether the data set carries timestamps.
+func (d *DataSet) HasTimestamps() bool {
+	return d.Timestamps != nil
+}
+
+// HasContexts checks whether the data set carries context features.
+func (d *DataSet) HasContexts() bool {
+	return d.Contexts != nil
+}
+
 func (d *DataSet) Length() int {
 	return len(d.Ratings)
 }
@@ -40,17 +62,30 @@ func (d *DataSet) Index(i int) (int, int, float64) {
 }
 
 func (d *DataSet) SubSet(indices []int) DataSet {
-	return NewRawSet(selectInt(d.Users, indices),
+	set := NewRawSet(selectInt(d.Users, indices),
 		selectInt(d.Items, indices),
 		selectFloat(d.Ratings, indices),
 	)
+	if d.HasTimestamps() {
+		set.Timestamps = selectInt64(d.Timestamps, indices)
+	}
+	if d.HasContexts() {
+		set.Contexts = make([]Features, len(indices))
+		for i, index := range indices {
+			set.Contexts[i] = d.Contexts[index]
+		}
+	}
+	set.UserDict = d.UserDict
+	set.ItemDict = d.ItemDict
+	set.Implicit = d.Implicit
+	return set
 }
 
 func (d *DataSet) KFold(k int, seed int64) ([]TrainSet, []DataSet) {
 	trainFolds := make([]TrainSet, k)
 	testFolds := make([]DataSet, k)
-	rand.New(rand.NewSource(seed))
-	perm := rand.Perm(d.Length())
+	rng := rand.New(rand.NewSource(seed))
+	perm := rng.Perm(d.Length())
 	foldSize := d.Length() / k
 	begin, end := 0, 0
 	for i := 0; i < k; i++ {
@@ -70,32 +105,55 @@ func (d *DataSet) KFold(k int, seed int64) ([]TrainSet, []DataSet) {
 }
 
 func (d *DataSet) Split(testSize float64, seed int64) (TrainSet, DataSet) {
-	rand.New(rand.NewSource(0))
-	perm := rand.Perm(d.Length())
+	rng := rand.New(rand.NewSource(seed))
+	perm := rng.Perm(d.Length())
 	mid := int(float64(d.Length()) * testSize)
 	testSet := d.SubSet(perm[:mid])
 	trainSet := d.SubSet(perm[mid:])
 	return NewTrainSet(trainSet), testSet
 }
 
-// ToCSV Save data set to csv.
+// ToCSV Save data set to csv. Timestamps are saved as the fourth column if exist.
+// Raw string IDs are saved if the data set has dictionaries.
 func (d *DataSet) ToCSV(fileName string, sep string) error {
 	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
 	defer file.Close()
-	if err == nil {
-		writer := bufio.NewWriter(file)
-		for i := range d.Ratings {
-			writer.WriteString(fmt.Sprintf("%v%s%v%s%v\n",
-				d.Users[i], sep,
-				d.Items[i], sep,
-				d.Ratings[i]))
+	writer := bufio.NewWriter(file)
+	for i := range d.Ratings {
+		var user, item interface{} = d.Users[i], d.Items[i]
+		if d.UserDict != nil {
+			user = d.UserDict.Decode(d.Users[i])
+		}
+		if d.ItemDict != nil {
+			item = d.ItemDict.Decode(d.Items[i])
 		}
+		writer.WriteString(fmt.Sprintf("%v%s%v%s%v",
+			user, sep,
+			item, sep,
+			d.Ratings[i]))
+		if d.HasTimestamps() {
+			writer.WriteString(fmt.Sprintf("%s%v", sep, d.Timestamps[i]))
+		}
+		writer.WriteString("\n")
 	}
-	return err
+	return writer.Flush()
+}
+
+// DataSetPredictor is an estimator which makes use of optional columns of a
+// data set (e.g. timestamps or contexts) in prediction.
+type DataSetPredictor interface {
+	PredictDataSet(dataSet DataSet) []float64
 }
 
-// Predict ratings for a set of <userId, itemId>s.
+// Predict ratings for a set of <userId, itemId>s. If the estimator is a
+// DataSetPredictor, optional columns of the data set are used as well.
 func (d *DataSet) Predict(estimator Estimator) []float64 {
+	if predictor, ok := estimator.(DataSetPredictor); ok {
+		return predictor.PredictDataSet(*d)
+	}
 	predictions := make([]float64, d.Length())
 	for j := 0; j < d.Length(); j++ {
 		userId, itemId, _ := d.Index(j)
@@ -112,10 +170,10 @@ type TrainSet struct {
 	UserCount  int
 	ItemCount  int
 
-	InnerUserIDs map[int]int
-	InnerItemIDs map[int]int
-	outerUserIDs []int
-	outerItemIDs []int
+	InnerUserIDs map[int]int // outer user ID -> inner user ID
+	InnerItemIDs map[int]int // outer item ID -> inner item ID
+	OuterUserIDs []int       // inner user ID -> outer user ID
+	OuterItemIDs []int       // inner item ID -> outer item ID
 
 	userRatings [][]IDRating
 	itemRatings [][]IDRating
@@ -133,19 +191,23 @@ func NewTrainSet(rowSet DataSet) TrainSet {
 	set.DataSet = rowSet
 	set.GlobalMean = stat.Mean(rowSet.Ratings, nil)
 
-	// 创建userID -> innerUserID的映射
+	// 创建userID <-> innerUserID的映射
 	set.InnerUserIDs = make(map[int]int)
+	set.OuterUserIDs = make([]int, 0)
 	for _, userID := range set.Users {
 		if _, exist := set.InnerUserIDs[userID]; !exist {
 			set.InnerUserIDs[userID] = set.UserCount
+			set.OuterUserIDs = append(set.OuterUserIDs, userID)
 			set.UserCount++
 		}
 	}
-	// 创建itemID -> innerItemID的映射
+	// 创建itemID <-> innerItemID的映射
 	set.InnerItemIDs = make(map[int]int)
+	set.OuterItemIDs = make([]int, 0)
 	for _, itemID := range set.Items {
 		if _, exist := set.InnerItemIDs[itemID]; !exist {
 			set.InnerItemIDs[itemID] = set.ItemCount
+			set.OuterItemIDs = append(set.OuterItemIDs, itemID)
 			set.ItemCount++
 		}
 	}
@@ -181,6 +243,16 @@ func (set *TrainSet) ConvertItemID(itemID int) int {
 	return newID
 }
 
+// OuterUserID 将内部用户ID转换为原始用户ID
+func (set *TrainSet) OuterUserID(innerUserID int) int {
+	return set.OuterUserIDs[innerUserID]
+}
+
+// OuterItemID 将内部物品ID转换为原始物品ID
+func (set *TrainSet) OuterItemID(innerItemID int) int {
+	return set.OuterItemIDs[innerItemID]
+}
+
 // UserRatings Get users' LeftRatings: an array of <itemId, rating> for each user.
 func (set *TrainSet) UserRatings() [][]IDRating {
 	if set.userRatings == nil {
@@ -233,15 +305,26 @@ func means(a [][]IDRating) []float64 {
 	}
 	return ret
 }
+
+// sorts sorts copies of rating vectors by IDs. Rating vectors (e.g. cached
+// by a train set) are unchanged.
 func sorts(rating [][]IDRating) []SortedIdRatings {
 	a := make([]SortedIdRatings, len(rating))
 	for i := range rating {
-		a[i] = SortedIdRatings{rating[i]}
-		sort.Sort(a[i])
+		a[i] = NewSortedIdRatings(append([]IDRating(nil), rating[i]...))
 	}
 	return a
 }
 
+// sortedRatings returns copies of rating vectors sorted by IDs.
+func sortedRatings(rating [][]IDRating) [][]IDRating {
+	ret := make([][]IDRating, len(rating))
+	for i, sorted := range sorts(rating) {
+		ret[i] = sorted.data
+	}
+	return ret
+}
+
 type SortedIdRatings struct {
 	data []IDRating
 }
@@ -266,47 +349,222 @@ func (sir SortedIdRatings) Less(i, j int) bool {
 
 /* loader */
 
-// Load build in data set
+// ParseError reports a malformed line in a data file.
+type ParseError struct {
+	FileName string
+	Line     int    // Line number, starting from 1
+	Field    int    // Field number, starting from 1. Zero if the line has too few fields.
+	Text     string // The offending field or line
+	Err      error
+}
+
+func (e *ParseError) Error() string {
+	if e.Field == 0 {
+		return fmt.Sprintf("%s:%d: %q: %v", e.FileName, e.Line, e.Text, e.Err)
+	}
+	return fmt.Sprintf("%s:%d: field %d %q: %v", e.FileName, e.Line, e.Field, e.Text, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Load build in data set. It exits the program on failure.
 func LoadDataFromBuiltIn(dataSetName string) DataSet {
+	data, _, err := TryLoadDataFromBuiltIn(dataSetName, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return data
+}
+
+// TryLoadDataFromBuiltIn loads a built-in data set, downloads it if not exists.
+// See TryLoadDataFromFile for strict mode and the number of skipped lines.
+func TryLoadDataFromBuiltIn(dataSetName string, strict bool) (DataSet, int, error) {
+	dataSet, err := prepareBuiltIn(dataSetName)
+	if err != nil {
+		return DataSet{}, 0, err
+	}
+	return TryLoadDataFromFile(filepath.Join(builtInDataFolder, dataSet.path), dataSet.sep, strict)
+}
+
+// prepareBuiltIn downloads a built-in data set if not exists.
+func prepareBuiltIn(dataSetName string) (_BuiltInDataSet, error) {
 	// Extract data set information
 	dataSet, exist := builtInDataSets[dataSetName]
 	if !exist {
-		log.Fatal("no such data set", dataSetName)
+		return dataSet, fmt.Errorf("no such data set %s", dataSetName)
 	}
-	const dataFolder = "data"
 	const tempFolder = "temp"
-	dataFileName := filepath.Join(dataFolder, dataSet.path)
+	dataFileName := filepath.Join(builtInDataFolder, dataSet.path)
 	if _, err := os.Stat(dataFileName); os.IsNotExist(err) {
-		zipFileName, _ := downloadFromUrl(dataSet.url, tempFolder)
-		unzip(zipFileName, dataFolder)
+		zipFileName, err := downloadFromUrl(dataSet.url, tempFolder)
+		if err != nil {
+			return dataSet, err
+		}
+		if _, err = unzip(zipFileName, builtInDataFolder); err != nil {
+			return dataSet, err
+		}
 	}
-	return LoadDataFromFile(dataFileName, dataSet.sep)
+	return dataSet, nil
 }
 
-// Load data from file
+// Load data from file. Malformed lines are skipped. It exits the program
+// if the file can't be read.
 func LoadDataFromFile(fileName string, sep string) DataSet {
+	data, _, err := TryLoadDataFromFile(fileName, sep, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return data
+}
+
+// TryLoadDataFromFile loads <user, item, rating[, timestamp]> lines from
+// file. Ratings could be fractional. Timestamps are loaded from the fourth
+// column if every line has one. Blank lines are ignored. In strict mode, a
+// malformed line fails the loading with a *ParseError. Otherwise, malformed
+// lines (e.g. a CSV header) are skipped and the number of skipped lines is
+// returned.
+func TryLoadDataFromFile(fileName string, sep string, strict bool) (DataSet, int, error) {
+	return loadDataFromFile(fileName, sep, strict, nil, nil, false)
+}
+
+// TryLoadStringDataFromFile loads data with string user and item IDs
+// (e.g. UUIDs or SKUs) from file. Raw IDs are encoded to dense ints in the
+// order of appearance, and the dictionaries are kept in DataSet.UserDict
+// and DataSet.ItemDict. See TryLoadDataFromFile for other details.
+func TryLoadStringDataFromFile(fileName string, sep string, strict bool) (DataSet, int, error) {
+	return loadDataFromFile(fileName, sep, strict, NewIDDictionary(), NewIDDictionary(), false)
+}
+
+// TryLoadImplicitDataFromFile loads implicit feedback such as clicks, views
+// and purchases from <user, item[, count[, timestamp]]> lines. The count is
+// 1 if absent. Duplicated <user, item> pairs are merged by summing counts
+// and keeping the latest timestamp. See TryLoadDataFromFile for other details.
+func TryLoadImplicitDataFromFile(fileName string, sep string, strict bool) (DataSet, int, error) {
+	return loadDataFromFile(fileName, sep, strict, nil, nil, true)
+}
+
+// TryLoadImplicitStringDataFromFile loads implicit feedback with string user
+// and item IDs. See TryLoadImplicitDataFromFile and TryLoadStringDataFromFile.
+func TryLoadImplicitStringDataFromFile(fileName string, sep string, strict bool) (DataSet, int, error) {
+	return loadDataFromFile(fileName, sep, strict, NewIDDictionary(), NewIDDictionary(), true)
+}
+
+// loadDataFromFile loads data from file. IDs are encoded by dictionaries if
+// given, otherwise they are parsed as integers.
+func loadDataFromFile(fileName string, sep string, strict bool, userDict, itemDict *IDDictionary, implicit bool) (DataSet, int, error) {
 	users := make([]int, 0)
 	items := make([]int, 0)
 	ratings := make([]float64, 0)
+	timestamps := make([]int64, 0)
+	hasTimestamps := true
+	skipped := 0
+	numericIDs := userDict == nil
 	// Open file
 	file, err := os.Open(fileName)
 	if err != nil {
-		log.Fatal(err)
+		return DataSet{}, 0, err
 	}
 	defer file.Close()
 	// Read CSV file
 	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
 		line := scanner.Text()
-		fields := strings.Split(line, sep)
-		user, _ := strconv.Atoi(fields[0])
-		item, _ := strconv.Atoi(fields[1])
-		rating, _ := strconv.Atoi(fields[2])
-		users = append(users, user)
-		items = append(items, item)
-		ratings = append(ratings, float64(rating))
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		record, parseErr := parseLine(line, sep, numericIDs, implicit)
+		if parseErr != nil {
+			parseErr.FileName = fileName
+			parseErr.Line = lineNumber
+			if strict {
+				return DataSet{}, skipped, parseErr
+			}
+			skipped++
+			continue
+		}
+		if numericIDs {
+			user, _ := strconv.Atoi(record.user)
+			item, _ := strconv.Atoi(record.item)
+			users = append(users, user)
+			items = append(items, item)
+		} else {
+			users = append(users, userDict.Encode(record.user))
+			items = append(items, itemDict.Encode(record.item))
+		}
+		ratings = append(ratings, record.rating)
+		if record.hasTimestamp && hasTimestamps {
+			timestamps = append(timestamps, record.timestamp)
+		} else {
+			hasTimestamps = false
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return DataSet{}, skipped, err
+	}
+	set := NewRawSet(users, items, ratings)
+	if hasTimestamps && len(timestamps) > 0 {
+		set.Timestamps = timestamps
+	}
+	set.UserDict = userDict
+	set.ItemDict = itemDict
+	if implicit {
+		set = mergeImplicit(set)
+	}
+	return set, skipped, nil
+}
+
+// ratingRecord is a parsed line of a data file.
+type ratingRecord struct {
+	user         string
+	item         string
+	rating       float64
+	timestamp    int64
+	hasTimestamp bool
+}
+
+// parseLine parses a <user, item, rating[, timestamp]> line. IDs are
+// checked to be integers if numericIDs is true. The rating is optional and
+// 1 by default for implicit feedback. The file name and line number of the
+// returned error are left blank.
+func parseLine(line string, sep string, numericIDs bool, implicit bool) (ratingRecord, *ParseError) {
+	record := ratingRecord{rating: 1}
+	fields := strings.Split(strings.TrimRight(line, "\r"), sep)
+	minFields := 3
+	if implicit {
+		minFields = 2
+	}
+	if len(fields) < minFields {
+		return record, &ParseError{Text: line, Err: fmt.Errorf("expect at least %d fields but got %d", minFields, len(fields))}
 	}
-	return NewRawSet(users, items, ratings)
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	record.user, record.item = fields[0], fields[1]
+	for i, id := range []string{record.user, record.item} {
+		if id == "" {
+			return record, &ParseError{Field: i + 1, Text: id, Err: fmt.Errorf("empty ID")}
+		}
+		if numericIDs {
+			if _, err := strconv.Atoi(id); err != nil {
+				return record, &ParseError{Field: i + 1, Text: id, Err: err}
+			}
+		}
+	}
+	var err error
+	if len(fields) > 2 {
+		if record.rating, err = strconv.ParseFloat(fields[2], 64); err != nil {
+			return record, &ParseError{Field: 3, Text: fields[2], Err: err}
+		}
+	}
+	if len(fields) > 3 {
+		if record.timestamp, err = strconv.ParseInt(fields[3], 10, 64); err != nil {
+			return record, &ParseError{Field: 4, Text: fields[3], Err: err}
+		}
+		record.hasTimestamp = true
+	}
+	return record, nil
 }
 
 // Download file from URL.
@@ -394,6 +652,9 @@ func unzip(src string, dst string) ([]string, error) {
 
 /* Built-in */
 
+// The folder of built-in data sets
+const builtInDataFolder = "data"
+
 // Built-in data set
 type _BuiltInDataSet struct {
 	url  string
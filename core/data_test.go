@@ -6,6 +6,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -46,3 +47,102 @@ func TestUnzip(t *testing.T) {
 		t.Fatal("Number of file doesn't match")
 	}
 }
+
+func TestLoadDataFromFileTimestamps(t *testing.T) {
+	data := LoadDataFromBuiltIn("ml-100k")
+	if !data.HasTimestamps() {
+		t.Fatal("timestamps are not loaded")
+	}
+	if len(data.Timestamps) != data.Length() {
+		t.Fatalf("Number of timestamps (%d) != %d", len(data.Timestamps), data.Length())
+	}
+	// 196	242	3	881250949
+	if data.Timestamps[0] != 881250949 {
+		t.Fatalf("Timestamp (%d) != %d", data.Timestamps[0], 881250949)
+	}
+	// Survive splitting
+	trainSet, testSet := data.Split(0.2, 0)
+	if len(trainSet.Timestamps) != trainSet.Length() || len(testSet.Timestamps) != testSet.Length() {
+		t.Fatal("timestamps are lost after splitting")
+	}
+}
+
+func TestToCSVTimestamps(t *testing.T) {
+	data := NewTimedRawSet([]int{1, 2}, []int{3, 4}, []float64{5, 1}, []int64{100, 200})
+	fileName := filepath.Join(t.TempDir(), "data.csv")
+	if err := data.ToCSV(fileName, ","); err != nil {
+		t.Fatal(err)
+	}
+	loaded := LoadDataFromFile(fileName, ",")
+	if !EqualInt(loaded.Users, data.Users) || !EqualInt(loaded.Items, data.Items) {
+		t.Fatal("users or items don't match")
+	}
+	if len(loaded.Timestamps) != 2 || loaded.Timestamps[0] != 100 || loaded.Timestamps[1] != 200 {
+		t.Fatalf("Timestamps (%v) don't match", loaded.Timestamps)
+	}
+}
+
+func TestTryLoadDataFromFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "ratings.csv")
+	content := "userId,movieId,rating,timestamp\n1,2,3.5,100\n1,x,4,200\n\n2,3,5,300\n"
+	if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Lenient mode
+	data, skipped, err := TryLoadDataFromFile(fileName, ",", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped != 2 {
+		t.Fatalf("Number of skipped lines (%d) != %d", skipped, 2)
+	}
+	if data.Length() != 2 || data.Ratings[0] != 3.5 {
+		t.Fatalf("Ratings (%v) don't match", data.Ratings)
+	}
+	// Strict mode
+	_, _, err = TryLoadDataFromFile(fileName, ",", true)
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("Expect *ParseError but got %v", err)
+	}
+	if parseErr.Line != 1 || parseErr.Field != 1 {
+		t.Fatalf("Error position (%d, %d) != (%d, %d)", parseErr.Line, parseErr.Field, 1, 1)
+	}
+	// Missing file
+	if _, _, err = TryLoadDataFromFile(filepath.Join(t.TempDir(), "missing"), ",", false); err == nil {
+		t.Fatal("Expect error for missing file")
+	}
+	// Unknown data set
+	if _, _, err = TryLoadDataFromBuiltIn("ml-unknown", false); err == nil {
+		t.Fatal("Expect error for unknown data set")
+	}
+}
+
+func TestTrainSetIDMapping(t *testing.T) {
+	trainSet := NewTrainSet(NewRawSet([]int{5, 3, 5}, []int{7, 7, 9}, []float64{1, 2, 3}))
+	for _, userId := range []int{3, 5} {
+		if trainSet.OuterUserID(trainSet.ConvertUserID(userId)) != userId {
+			t.Fatalf("User %d isn't mapped back", userId)
+		}
+	}
+	for _, itemId := range []int{7, 9} {
+		if trainSet.OuterItemID(trainSet.ConvertItemID(itemId)) != itemId {
+			t.Fatalf("Item %d isn't mapped back", itemId)
+		}
+	}
+	// Survive save and load
+	fileName := filepath.Join(t.TempDir(), "train.set")
+	if err := Save(fileName, trainSet); err != nil {
+		t.Fatal(err)
+	}
+	loaded := TrainSet{}
+	if err := Load(fileName, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if !EqualInt(loaded.OuterUserIDs, []int{5, 3}) || !EqualInt(loaded.OuterItemIDs, []int{7, 9}) {
+		t.Fatalf("Outer IDs (%v, %v) don't match", loaded.OuterUserIDs, loaded.OuterItemIDs)
+	}
+	if loaded.ConvertUserID(3) != 1 || loaded.ConvertItemID(9) != 1 {
+		t.Fatal("Inner IDs don't match")
+	}
+}
@@ -0,0 +1,73 @@
+package core
+
+// IDDictionary encodes raw string IDs (e.g. UUIDs or SKUs) to dense int
+// IDs starting from 0, and decodes them back.
+type IDDictionary struct {
+	IDs   map[string]int
+	Names []string
+}
+
+// NewIDDictionary creates an empty dictionary.
+func NewIDDictionary() *IDDictionary {
+	return &IDDictionary{
+		IDs:   make(map[string]int),
+		Names: make([]string, 0),
+	}
+}
+
+// Len returns the number of IDs in the dictionary.
+func (dict *IDDictionary) Len() int {
+	return len(dict.Names)
+}
+
+// Encode converts a raw ID to an int ID. A new int ID is assigned if the
+// raw ID doesn't exist.
+func (dict *IDDictionary) Encode(name string) int {
+	if id, exist := dict.IDs[name]; exist {
+		return id
+	}
+	id := len(dict.Names)
+	dict.IDs[name] = id
+	dict.Names = append(dict.Names, name)
+	return id
+}
+
+// ID converts a raw ID to an int ID. It returns -1 if the raw ID doesn't
+// exist, which is unknown to every estimator.
+func (dict *IDDictionary) ID(name string) int {
+	if id, exist := dict.IDs[name]; exist {
+		return id
+	}
+	return newID
+}
+
+// Decode converts an int ID back to the raw ID.
+func (dict *IDDictionary) Decode(id int) string {
+	return dict.Names[id]
+}
+
+// NameScore is a raw string ID with its score.
+type NameScore struct {
+	Name  string
+	Score float64
+}
+
+// PredictByName predicts the rating with raw string IDs. The estimator
+// must be fitted on a data set loaded with string IDs.
+func PredictByName(estimator Estimator, userName, itemName string) float64 {
+	trainSet := estimator.GetTrainSet()
+	return estimator.Predict(trainSet.UserDict.ID(userName), trainSet.ItemDict.ID(itemName))
+}
+
+// RecommendByName recommends items for a user with raw string IDs. The
+// estimator must be fitted on a data set loaded with string IDs. Item IDs
+// in options are encoded IDs, which could be obtained by ItemDict.ID.
+func RecommendByName(estimator Estimator, userName string, n int, options *RecommendOptions) []NameScore {
+	trainSet := estimator.GetTrainSet()
+	items := Recommend(estimator, trainSet.UserDict.ID(userName), n, options)
+	ret := make([]NameScore, len(items))
+	for i, item := range items {
+		ret[i] = NameScore{Name: trainSet.ItemDict.Decode(item.ID), Score: item.Score}
+	}
+	return ret
+}
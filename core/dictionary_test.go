@@ -0,0 +1,56 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIDDictionary(t *testing.T) {
+	dict := NewIDDictionary()
+	if dict.Encode("B00X4WHP5E") != 0 || dict.Encode("B00ZV9RDKK") != 1 || dict.Encode("B00X4WHP5E") != 0 {
+		t.Fatal("IDs are not dense")
+	}
+	if dict.Len() != 2 {
+		t.Fatalf("Length (%d) != %d", dict.Len(), 2)
+	}
+	if dict.Decode(1) != "B00ZV9RDKK" {
+		t.Fatalf("Name (%s) != %s", dict.Decode(1), "B00ZV9RDKK")
+	}
+	if dict.ID("unknown") != newID {
+		t.Fatal("unknown name should be encoded as a new ID")
+	}
+}
+
+func TestLoadStringData(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "ratings.csv")
+	content := "user,item,rating\nalice,B00X4WHP5E,5\nalice,B00ZV9RDKK,1\nbob,B00X4WHP5E,4\nbob,B01BX5YO9K,2\ncarol,B01BX5YO9K,3\n"
+	if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, skipped, err := TryLoadStringDataFromFile(fileName, ",", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skipped != 1 {
+		t.Fatalf("Number of skipped lines (%d) != %d", skipped, 1)
+	}
+	if data.UserDict.Len() != 3 || data.ItemDict.Len() != 3 {
+		t.Fatal("Number of users or items doesn't match")
+	}
+	// Dictionaries survive splitting
+	trainSet, testSet := data.Split(0.2, 0)
+	if trainSet.UserDict != data.UserDict || testSet.ItemDict != data.ItemDict {
+		t.Fatal("dictionaries are lost after splitting")
+	}
+	// Predict and recommend with raw IDs
+	baseLine := NewBaseLine(nil)
+	baseLine.Fit(NewTrainSet(data))
+	if prediction := PredictByName(baseLine, "alice", "B00X4WHP5E"); prediction != baseLine.Predict(0, 0) {
+		t.Fatalf("Prediction (%.3f) != %.3f", prediction, baseLine.Predict(0, 0))
+	}
+	items := RecommendByName(baseLine, "alice", 10, nil)
+	if len(items) != 1 || items[0].Name != "B01BX5YO9K" {
+		t.Fatalf("Recommendation (%v) doesn't match", items)
+	}
+}
@@ -3,6 +3,7 @@ package core
 import (
 	"fmt"
 	"path/filepath"
+	"sync"
 	"testing"
 )
 
@@ -11,7 +12,7 @@ func TestSave(t *testing.T) {
 	dataSet := LoadDataFromBuiltIn("ml-100k")
 	estimator1 := NewSVD(nil)
 	estimator1.Fit(NewTrainSet(dataSet))
-	err1 := RMSE(dataSet.Predict(estimator1), dataSet.Ratings)
+	err1 := RMSE(estimator1, dataSet)
 	// Save the model
 	fmt.Printf("模型数据文件位置 %s\n", filepath.Join(tempDir, "svd.m"))
 	if err := Save(filepath.Join(tempDir, "/svd.m"), estimator1); err != nil {
@@ -22,8 +23,35 @@ func TestSave(t *testing.T) {
 	if err := Load(filepath.Join(tempDir, "/svd.m"), &estimator2); err != nil {
 		t.Fatal(err)
 	}
-	err2 := RMSE(dataSet.Predict(estimator2), dataSet.Ratings)
+	err2 := RMSE(estimator2, dataSet)
 	if err1 != err2 {
 		t.Fatalf("The model restored from the file has different accuracy: %v != %v", err1, err2)
 	}
 }
+
+func TestSaveRandom(t *testing.T) {
+	dataSet := LoadDataFromBuiltIn("ml-100k")
+	estimator1 := NewRandom(Parameters{"seed": 1})
+	estimator1.Fit(NewTrainSet(dataSet))
+	if err := Save(filepath.Join(tempDir, "/random.m"), estimator1); err != nil {
+		t.Fatal(err)
+	}
+	estimator2 := NewRandom(nil)
+	if err := Load(filepath.Join(tempDir, "/random.m"), estimator2); err != nil {
+		t.Fatal(err)
+	}
+	// The restored model predicts from the same seed
+	if err1, err2 := RMSE(estimator1, dataSet), RMSE(estimator2, dataSet); err1 != err2 {
+		t.Fatalf("The model restored from the file has different accuracy: %v != %v", err1, err2)
+	}
+	// Predict concurrently
+	var wg sync.WaitGroup
+	wg.Add(4)
+	for i := 0; i < 4; i++ {
+		go func() {
+			defer wg.Done()
+			estimator2.Predict(1, 1)
+		}()
+	}
+	wg.Wait()
+}
@@ -4,6 +4,8 @@ import (
 	"gonum.org/v1/gonum/stat"
 	"math"
 	"reflect"
+	"runtime"
+	"sort"
 )
 
 // ParameterGrid 实际上就是一个二维数组
@@ -14,17 +16,24 @@ type CrossValidateResult struct {
 	Tests  []float64
 }
 
-// CrossValidate 验证推荐算法性能
-func CrossValidate(estimator Estimator, dataSet DataSet, metrics []Evaluator, cv int, seed int64,
+// CrossValidate 验证推荐算法性能，由 splitter 分割训练集与测试集，
+// 例如 NewKFoldSplitter(5, 0)
+func CrossValidate(estimator Estimator, dataSet DataSet, metrics []Evaluator, splitter Splitter,
 	params Parameters, nJobs int) []CrossValidateResult {
+	// 分割测试集合
+	trainFolds, testFolds := splitter.Split(dataSet)
+	return CrossValidateFolds(estimator, trainFolds, testFolds, metrics, params, nJobs)
+}
 
+// CrossValidateFolds 在给定的训练集与测试集上验证推荐算法性能
+func CrossValidateFolds(estimator Estimator, trainFolds []TrainSet, testFolds []DataSet, metrics []Evaluator,
+	params Parameters, nJobs int) []CrossValidateResult {
+	cv := len(trainFolds)
 	ret := make([]CrossValidateResult, len(metrics))
 	for i := 0; i < len(ret); i++ {
 		ret[i].Trains = make([]float64, cv)
 		ret[i].Tests = make([]float64, cv)
 	}
-	// 分割测试集合
-	trainFolds, testFolds := dataSet.KFold(cv, seed)
 	parallel(cv, nJobs, func(begin, end int) {
 		cp := reflect.New(reflect.TypeOf(estimator).Elem()).Interface().(Estimator)
 		Copy(cp, estimator)
@@ -34,10 +43,8 @@ func CrossValidate(estimator Estimator, dataSet DataSet, metrics []Evaluator, cv
 			cp.SetParams(params)
 			cp.Fit(trainFold)
 			// Evaluate on test set
-			testRatings := testFold.Ratings
-			testPredictions := testFold.Predict(cp)
 			for j := 0; j < len(metrics); j++ {
-				ret[j].Tests[i] = metrics[j](testPredictions, testRatings)
+				ret[j].Tests[i] = metrics[j].Evaluate(cp, testFold)
 			}
 			// Evaluate on train set
 		}
@@ -76,7 +83,9 @@ type GridSearchResult struct {
 
 // GridSearchCV Tune algorithm parameters with GridSearchCV
 func GridSearchCV(algo Estimator, dataSet DataSet, paramGrid ParameterGrid,
-	evaluators []Evaluator, cv int, seed int64) []GridSearchResult {
+	evaluators []Evaluator, splitter Splitter) []GridSearchResult {
+	// 所有参数组合使用相同的分割
+	trainFolds, testFolds := splitter.Split(dataSet)
 	// 获取参数名字和长度
 	params := make([]string, 0, len(paramGrid))
 	count := 1
@@ -92,7 +101,12 @@ func GridSearchCV(algo Estimator, dataSet DataSet, paramGrid ParameterGrid,
 	// 初始化
 	for i := range results {
 		results[i] = GridSearchResult{}
-		results[i].BestScore = math.Inf(1)
+		// 排序指标越大越好，误差指标越小越好
+		if evaluators[i].Maximize() {
+			results[i].BestScore = math.Inf(-1)
+		} else {
+			results[i].BestScore = math.Inf(1)
+		}
 		results[i].CVResult = make([]CrossValidateResult, 0, count)
 		results[i].AllParams = make([]Parameters, 0, count)
 	}
@@ -104,7 +118,7 @@ func GridSearchCV(algo Estimator, dataSet DataSet, paramGrid ParameterGrid,
 		// 当deep == len(params) 时候，说明已经遍历完所有参数
 		if deep == len(params) {
 			// Cross validate
-			cvResult := CrossValidate(algo, dataSet, evaluators, cv, seed, options)
+			cvResult := CrossValidateFolds(algo, trainFolds, testFolds, evaluators, options, runtime.NumCPU())
 
 			for i := range cvResult {
 				results[i].CVResult = append(results[i].CVResult, cvResult[i])
@@ -112,7 +126,11 @@ func GridSearchCV(algo Estimator, dataSet DataSet, paramGrid ParameterGrid,
 				results[i].AllParams = append(results[i].AllParams, options.Copy())
 				// 计算测试集平均分
 				score := stat.Mean(cvResult[i].Tests, nil)
-				if score < results[i].BestScore {
+				better := score < results[i].BestScore
+				if evaluators[i].Maximize() {
+					better = score > results[i].BestScore
+				}
+				if better {
 					results[i].BestScore = score
 					results[i].BestParams = options.Copy()
 					results[i].BestIndex = len(results[i].AllParams) - 1
@@ -154,8 +172,8 @@ func GridSearchCV(algo Estimator, dataSet DataSet, paramGrid ParameterGrid,
 //
 // 返回值：
 // - Evaluator: 评估函数，接受估计器和测试集，返回AUC值
-func NewAUC(fullSet DataSet) Evaluator {
-	return func(estimator Estimator, testSet DataSet) float64 {
+func NewAUC(fullSet DataSet) RankingEvaluator {
+	return RankingEvaluator(func(estimator Estimator, testSet DataSet) float64 {
 		// 将数据集转换为TrainSet格式，便于处理用户-物品映射
 		full := NewTrainSet(fullSet)
 		test := NewTrainSet(testSet)
@@ -166,7 +184,7 @@ func NewAUC(fullSet DataSet) Evaluator {
 		// 遍历测试集中的每个用户
 		for innerUserIDTest, testRatings := range test.UserRatings() {
 			// 获取用户的外部ID（原始用户ID）
-			userID := test.outerUserIDs[innerUserIDTest]
+			userID := test.OuterUserIDs[innerUserIDTest]
 
 			// 在完整数据集中查找该用户的内部ID
 			innerUserIDFull := full.ConvertUserID(userID)
@@ -180,8 +198,8 @@ func NewAUC(fullSet DataSet) Evaluator {
 			// key: 物品的外部ID, value: 评分值
 			fullRatedItems := make(map[int]float64)
 			for _, rating := range full.UserRatings()[innerUserIDFull] {
-				// 修复错误：应该使用outerItemIDs而不是outerUserIDs
-				itemID := full.outerItemIDs[rating.ID]
+				// 修复错误：应该使用OuterItemIDs而不是OuterUserIDs
+				itemID := full.OuterItemIDs[rating.ID]
 				fullRatedItems[itemID] = rating.Rating
 			}
 
@@ -190,12 +208,12 @@ func NewAUC(fullSet DataSet) Evaluator {
 
 			// 遍历测试集中该用户的每个评分物品（正样本）
 			for _, testRating := range testRatings {
-				// 修复错误：应该使用outerItemIDs而不是outerUserIDs
-				positiveItemID := test.outerItemIDs[testRating.ID]
+				// 修复错误：应该使用OuterItemIDs而不是OuterUserIDs
+				positiveItemID := test.OuterItemIDs[testRating.ID]
 
 				// 遍历完整数据集中的所有物品，寻找负样本
 				for j := 0; j < full.ItemCount; j++ {
-					negativeItemID := full.outerItemIDs[j]
+					negativeItemID := full.OuterItemIDs[j]
 
 					// 如果该物品在完整数据集中未被用户评分，则作为负样本
 					if _, exists := fullRatedItems[negativeItemID]; !exists {
@@ -226,5 +244,139 @@ func NewAUC(fullSet DataSet) Evaluator {
 			return userAUCSum / userCount
 		}
 		return 0.5
+	})
+}
+
+// newRankingEvaluator 创建Top-K排序评估器
+//
+// 对测试集中的每个用户，使用 Recommend 生成长度为K的推荐列表（排除训练集中已评分的物品），
+// 测试集中评分不低于 threshold 的物品视为相关物品。没有相关物品的用户被跳过，
+// 最终结果为所有用户指标的平均值，与 NewAUC 一致。
+func newRankingEvaluator(k int, threshold float64, metric func(ranked []IDScore, relevant Set) float64) RankingEvaluator {
+	return RankingEvaluator(func(estimator Estimator, testSet DataSet) float64 {
+		// 收集每个用户的相关物品
+		relevantItems := make(map[int]Set)
+		for i := 0; i < testSet.Length(); i++ {
+			userId, itemId, rating := testSet.Index(i)
+			if rating < threshold {
+				continue
+			}
+			if _, exist := relevantItems[userId]; !exist {
+				relevantItems[userId] = make(Set)
+			}
+			relevantItems[userId][itemId] = nil
+		}
+		// 固定用户顺序，保证结果可复现
+		users := make([]int, 0, len(relevantItems))
+		for userId := range relevantItems {
+			users = append(users, userId)
+		}
+		sort.Ints(users)
+		// 计算所有用户的平均值
+		sum := 0.0
+		for _, userId := range users {
+			ranked := Recommend(estimator, userId, k, nil)
+			sum += metric(ranked, relevantItems[userId])
+		}
+		if len(users) == 0 {
+			return 0
+		}
+		return sum / float64(len(users))
+	})
+}
+
+// NewPrecision 创建Precision@K评估器
+//
+//	Precision@K = |R_u ∩ T_u| / K
+//
+// 其中 R_u 为用户u的Top-K推荐列表，T_u 为用户u在测试集中的相关物品。
+func NewPrecision(k int, threshold float64) RankingEvaluator {
+	return newRankingEvaluator(k, threshold, func(ranked []IDScore, relevant Set) float64 {
+		return float64(countHits(ranked, relevant)) / float64(k)
+	})
+}
+
+// NewRecall 创建Recall@K评估器
+//
+//	Recall@K = |R_u ∩ T_u| / |T_u|
+func NewRecall(k int, threshold float64) RankingEvaluator {
+	return newRankingEvaluator(k, threshold, func(ranked []IDScore, relevant Set) float64 {
+		return float64(countHits(ranked, relevant)) / float64(len(relevant))
+	})
+}
+
+// NewNDCG 创建NDCG@K (Normalized Discounted Cumulative Gain) 评估器
+//
+//	DCG@K = Σ_{i=1}^{K} rel_i / log2(i+1)
+//	NDCG@K = DCG@K / IDCG@K
+//
+// 其中 rel_i 表示第i个推荐物品是否相关，IDCG@K 为理想排序下的 DCG@K。
+func NewNDCG(k int, threshold float64) RankingEvaluator {
+	return newRankingEvaluator(k, threshold, func(ranked []IDScore, relevant Set) float64 {
+		dcg, idcg := 0.0, 0.0
+		for i, item := range ranked {
+			if _, exist := relevant[item.ID]; exist {
+				dcg += 1.0 / math.Log2(float64(i)+2)
+			}
+		}
+		for i := 0; i < len(relevant) && i < k; i++ {
+			idcg += 1.0 / math.Log2(float64(i)+2)
+		}
+		return dcg / idcg
+	})
+}
+
+// NewMAP 创建MAP@K (Mean Average Precision) 评估器
+//
+//	AP@K = (1/min(|T_u|, K)) * Σ_{i=1}^{K} Precision@i * rel_i
+func NewMAP(k int, threshold float64) RankingEvaluator {
+	return newRankingEvaluator(k, threshold, func(ranked []IDScore, relevant Set) float64 {
+		sum, hits := 0.0, 0.0
+		for i, item := range ranked {
+			if _, exist := relevant[item.ID]; exist {
+				hits++
+				sum += hits / float64(i+1)
+			}
+		}
+		return sum / math.Min(float64(len(relevant)), float64(k))
+	})
+}
+
+// NewMRR 创建MRR@K (Mean Reciprocal Rank) 评估器
+//
+//	RR@K = 1 / rank_u
+//
+// 其中 rank_u 为第一个相关物品在推荐列表中的位置，列表中没有相关物品时 RR@K = 0。
+func NewMRR(k int, threshold float64) RankingEvaluator {
+	return newRankingEvaluator(k, threshold, func(ranked []IDScore, relevant Set) float64 {
+		for i, item := range ranked {
+			if _, exist := relevant[item.ID]; exist {
+				return 1.0 / float64(i+1)
+			}
+		}
+		return 0
+	})
+}
+
+// NewHitRate 创建HitRate@K评估器
+//
+//	HitRate@K = I(|R_u ∩ T_u| > 0)
+func NewHitRate(k int, threshold float64) RankingEvaluator {
+	return newRankingEvaluator(k, threshold, func(ranked []IDScore, relevant Set) float64 {
+		if countHits(ranked, relevant) > 0 {
+			return 1
+		}
+		return 0
+	})
+}
+
+// countHits 统计推荐列表中相关物品的数量
+func countHits(ranked []IDScore, relevant Set) int {
+	hits := 0
+	for _, item := range ranked {
+		if _, exist := relevant[item.ID]; exist {
+			hits++
+		}
 	}
+	return hits
 }
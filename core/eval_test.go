@@ -14,7 +14,7 @@ func TestGridSearchCV(t *testing.T) {
 		"lr":      {0.002, 0.005},
 	}
 	out := GridSearchCV(NewBaseLine(nil), LoadDataFromBuiltIn("ml-100k"), paramGrid,
-		[]Evaluator{RMSE, MAE}, 5, 0)
+		[]Evaluator{RMSE, MAE}, NewKFoldSplitter(5, 0))
 	// Check best parameters
 	bestParams := out[0].BestParams
 	if bestParams.GetInt("nEpochs", -1) != 10 {
@@ -29,18 +29,104 @@ func TestGridSearchCV(t *testing.T) {
 	}
 }
 
+// constEstimator predicts the same score for every pair.
+type constEstimator struct {
+	Base
+	value float64
+}
+
+func (c *constEstimator) Predict(userId, itemId int) float64 {
+	return c.value
+}
+
 func TestRMSE(t *testing.T) {
-	a := []float64{-2.0, 0, 2.0}
-	b := []float64{0, 0, 0}
-	if math.Abs(RMSE(a, b)-1.63299) > 0.00001 {
+	a := NewRawSet([]int{0, 1, 2}, []int{0, 1, 2}, []float64{-2.0, 0, 2.0})
+	b := &constEstimator{value: 0}
+	if math.Abs(RMSE(b, a)-1.63299) > 0.00001 {
 		t.Fail()
 	}
 }
 
 func TestMAE(t *testing.T) {
-	a := []float64{-2.0, 0, 2.0}
-	b := []float64{0, 0, 0}
-	if math.Abs(MAE(a, b)-1.33333) > 0.00001 {
+	a := NewRawSet([]int{0, 1, 2}, []int{0, 1, 2}, []float64{-2.0, 0, 2.0})
+	b := &constEstimator{value: 0}
+	if math.Abs(MAE(b, a)-1.33333) > 0.00001 {
 		t.Fail()
 	}
 }
+
+// itemEstimator predicts the item ID as the score.
+type itemEstimator struct {
+	Base
+}
+
+func (e *itemEstimator) Predict(userId, itemId int) float64 {
+	return float64(itemId)
+}
+
+func (e *itemEstimator) Fit(trainSet TrainSet) {
+	e.Data = trainSet
+}
+
+func TestRankingEvaluators(t *testing.T) {
+	estimator := &itemEstimator{}
+	estimator.Fit(NewTrainSet(NewRawSet(
+		[]int{1, 2, 2, 2, 2, 2},
+		[]int{1, 2, 3, 4, 5, 6},
+		[]float64{1, 1, 1, 1, 1, 1},
+	)))
+	// Top-3 items: 6, 5, 4. Relevant items: 5, 3.
+	testSet := NewRawSet([]int{1, 1, 1}, []int{5, 3, 2}, []float64{5, 4, 1})
+	evaluators := map[string]Evaluator{
+		"Precision": NewPrecision(3, 4),
+		"Recall":    NewRecall(3, 4),
+		"NDCG":      NewNDCG(3, 4),
+		"MAP":       NewMAP(3, 4),
+		"MRR":       NewMRR(3, 4),
+		"HitRate":   NewHitRate(3, 4),
+	}
+	expects := map[string]float64{
+		"Precision": 1.0 / 3,
+		"Recall":    0.5,
+		"NDCG":      (1 / math.Log2(3)) / (1 + 1/math.Log2(3)),
+		"MAP":       0.25,
+		"MRR":       0.5,
+		"HitRate":   1,
+	}
+	for name, evaluator := range evaluators {
+		if score := evaluator.Evaluate(estimator, testSet); math.Abs(score-expects[name]) > 0.00001 {
+			t.Fatalf("%s(%.5f) != %.5f", name, score, expects[name])
+		}
+		if !evaluator.Maximize() {
+			t.Fatalf("%s should be maximized", name)
+		}
+	}
+	if RMSE.Maximize() || MAE.Maximize() {
+		t.Fatal("RMSE and MAE should be minimized")
+	}
+}
+
+func TestAUC(t *testing.T) {
+	fullSet := NewRawSet([]int{1, 1, 2, 2, 2}, []int{1, 4, 2, 3, 4}, []float64{1, 1, 1, 1, 1})
+	estimator := &itemEstimator{}
+	estimator.Fit(NewTrainSet(fullSet))
+	// Item 4 is ranked above the negative items 2 and 3.
+	testSet := NewRawSet([]int{1}, []int{4}, []float64{1})
+	if auc := NewAUC(fullSet)(estimator, testSet); auc != 1 {
+		t.Fatalf("AUC(%.3f) != %.3f", auc, 1.0)
+	}
+}
+
+func TestEvaluatorDirection(t *testing.T) {
+	// 同一函数字面量生成的评估器方向互不影响
+	newEvaluator := func(maximize bool) Evaluator {
+		score := func(estimator Estimator, testSet DataSet) float64 { return 0 }
+		if maximize {
+			return RankingEvaluator(score)
+		}
+		return ErrorEvaluator(score)
+	}
+	if maximized, minimized := newEvaluator(true), newEvaluator(false); !maximized.Maximize() || minimized.Maximize() {
+		t.Fatal("directions of evaluators are mixed up")
+	}
+}
@@ -0,0 +1,430 @@
+package core
+
+import (
+	"gonum.org/v1/gonum/stat"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+const (
+	regressionLoss = "regression"
+	bprLoss        = "bpr"
+	sgdOptimizer   = "sgd"
+	alsOptimizer   = "als"
+)
+
+// FeatureValue is a non-zero element of a sparse feature vector.
+type FeatureValue struct {
+	Index int
+	Value float64
+}
+
+// FM is the factorization machine (Rendle). Each rating is encoded as a
+// sparse feature vector x, which consists of the one-hot user ("user=ID"),
+// the one-hot item ("item=ID"), side features of the user and the item, and
+// context features of the rating. The prediction is:
+//
+//	\hat{y}(x) = w_0 + Σ_j w_jx_j + Σ_j Σ_{j'>j} <v_j, v_{j'}> x_jx_{j'}
+//
+// Categorical features are one-hot and numeric features are standardized.
+// Features not seen in training are ignored, so users (items) unknown to
+// the train set are still predicted by their side features.
+type FM struct {
+	Base
+	SideInfo       *SideInfo          // Side information of users and items. Nil if not used.
+	FeatureIndex   map[string]int     // feature name -> feature index
+	NumericMeans   map[string]float64 // Means of numeric features
+	NumericStdDevs map[string]float64 // Standard deviations of numeric features
+	GlobalBias     float64            // w_0
+	Weights        []float64          // w_j
+	Factors        [][]float64        // v_j
+	NFactors       int                // The number of latent factors
+	userVectors    [][]FeatureValue   // Cached feature vectors of inner users
+	itemVectors    [][]FeatureValue   // Cached feature vectors of inner items
+}
+
+func NewFM(params Parameters) *FM {
+	fm := new(FM)
+	fm.Params = params
+	return fm
+}
+
+// SupportImplicit reports that FM could be trained on implicit feedback
+// with the BPR loss.
+func (fm *FM) SupportImplicit() bool {
+	return fm.Params.GetString("loss", regressionLoss) == bprLoss
+}
+
+func (fm *FM) Predict(userId, itemId int) float64 {
+	return fm.PredictWithContext(userId, itemId, Features{})
+}
+
+// PredictWithContext predicts the rating of a user to an item with context
+// features of the rating.
+func (fm *FM) PredictWithContext(userId, itemId int, context Features) float64 {
+	x := make([]FeatureValue, 0)
+	x = append(x, fm.userVector(userId)...)
+	x = append(x, fm.itemVector(itemId)...)
+	x = fm.appendFeatures(x, context)
+	return fm.predictVector(x, make([]float64, fm.NFactors))
+}
+
+// PredictDataSet predicts all ratings in a data set, including context
+// features if exist.
+func (fm *FM) PredictDataSet(dataSet DataSet) []float64 {
+	predictions := make([]float64, dataSet.Length())
+	for i := range predictions {
+		context := Features{}
+		if dataSet.HasContexts() {
+			context = dataSet.Contexts[i]
+		}
+		predictions[i] = fm.PredictWithContext(dataSet.Users[i], dataSet.Items[i], context)
+	}
+	return predictions
+}
+
+// predictVector computes \hat{y}(x) in O(kn) by
+//
+//	Σ_j Σ_{j'>j} <v_j, v_{j'}> x_jx_{j'} = 1/2 Σ_f ((Σ_j v_{jf}x_j)^2 - Σ_j v_{jf}^2x_j^2)
+//
+// Sums Σ_j v_{jf}x_j are saved to sums, which are reused by gradients.
+func (fm *FM) predictVector(x []FeatureValue, sums []float64) float64 {
+	ret := fm.GlobalBias
+	for _, fv := range x {
+		ret += fm.Weights[fv.Index] * fv.Value
+	}
+	for f := range sums {
+		sum, sumSquare := 0.0, 0.0
+		for _, fv := range x {
+			v := fm.Factors[fv.Index][f] * fv.Value
+			sum += v
+			sumSquare += v * v
+		}
+		sums[f] = sum
+		ret += (sum*sum - sumSquare) / 2
+	}
+	return ret
+}
+
+// appendFeatures appends known features to a feature vector. Numeric
+// features are appended in the order of names so that predictions are
+// deterministic.
+func (fm *FM) appendFeatures(x []FeatureValue, features Features) []FeatureValue {
+	for _, name := range features.Categorical {
+		if index, exist := fm.FeatureIndex[name]; exist {
+			x = append(x, FeatureValue{index, 1})
+		}
+	}
+	names := make([]string, 0, len(features.Numeric))
+	for name := range features.Numeric {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if index, exist := fm.FeatureIndex[name]; exist {
+			value := (features.Numeric[name] - fm.NumericMeans[name]) / fm.NumericStdDevs[name]
+			x = append(x, FeatureValue{index, value})
+		}
+	}
+	return x
+}
+
+func (fm *FM) userVector(userId int) []FeatureValue {
+	if innerUserId := fm.Data.ConvertUserID(userId); innerUserId != newID && fm.userVectors != nil {
+		return fm.userVectors[innerUserId]
+	}
+	x := make([]FeatureValue, 0)
+	if index, exist := fm.FeatureIndex["user="+strconv.Itoa(userId)]; exist {
+		x = append(x, FeatureValue{index, 1})
+	}
+	if fm.SideInfo != nil {
+		x = fm.appendFeatures(x, fm.SideInfo.UserFeatures(userId))
+	}
+	return x
+}
+
+func (fm *FM) itemVector(itemId int) []FeatureValue {
+	if innerItemId := fm.Data.ConvertItemID(itemId); innerItemId != newID && fm.itemVectors != nil {
+		return fm.itemVectors[innerItemId]
+	}
+	x := make([]FeatureValue, 0)
+	if index, exist := fm.FeatureIndex["item="+strconv.Itoa(itemId)]; exist {
+		x = append(x, FeatureValue{index, 1})
+	}
+	if fm.SideInfo != nil {
+		x = fm.appendFeatures(x, fm.SideInfo.ItemFeatures(itemId))
+	}
+	return x
+}
+
+// indexFeatures assigns indices to users, items and features appear in a
+// train set, and computes statistics of numeric features.
+func (fm *FM) indexFeatures(trainSet TrainSet) {
+	fm.FeatureIndex = make(map[string]int)
+	numeric := make(map[string][]float64)
+	add := func(features Features) {
+		for _, name := range features.Categorical {
+			if _, exist := fm.FeatureIndex[name]; !exist {
+				fm.FeatureIndex[name] = len(fm.FeatureIndex)
+			}
+		}
+		for name, value := range features.Numeric {
+			numeric[name] = append(numeric[name], value)
+		}
+	}
+	for _, userId := range trainSet.OuterUserIDs {
+		fm.FeatureIndex["user="+strconv.Itoa(userId)] = len(fm.FeatureIndex)
+	}
+	for _, itemId := range trainSet.OuterItemIDs {
+		fm.FeatureIndex["item="+strconv.Itoa(itemId)] = len(fm.FeatureIndex)
+	}
+	if fm.SideInfo != nil {
+		for _, features := range fm.SideInfo.InnerUserFeatures(trainSet) {
+			add(features)
+		}
+		for _, features := range fm.SideInfo.InnerItemFeatures(trainSet) {
+			add(features)
+		}
+	}
+	for _, features := range trainSet.Contexts {
+		add(features)
+	}
+	// 数值特征标准化
+	names := make([]string, 0, len(numeric))
+	for name := range numeric {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fm.NumericMeans = make(map[string]float64)
+	fm.NumericStdDevs = make(map[string]float64)
+	for _, name := range names {
+		fm.FeatureIndex[name] = len(fm.FeatureIndex)
+		mean, stdDev := stat.MeanStdDev(numeric[name], nil)
+		if stdDev == 0 || math.IsNaN(stdDev) {
+			stdDev = 1
+		}
+		fm.NumericMeans[name] = mean
+		fm.NumericStdDevs[name] = stdDev
+	}
+}
+
+// Fit a FM model. The loss is either the squared loss of ratings
+// ("regression") or the BPR loss of implicit feedback ("bpr"). The squared
+// loss could be optimized by SGD or ALS (coordinate descent, Rendle 2012),
+// while the BPR loss could only be optimized by SGD. For the BPR loss, each
+// epoch draws as many ratings as the train set, and a negative item is
+// sampled for each rating with the user and the context unchanged.
+// Parameters:
+//
+//	 sideInfo	- The side information of users and items. Default is nil.
+//	 loss		- The loss function, "regression" or "bpr". Default is "regression".
+//	 optimizer	- The optimizer, "sgd" or "als". Default is "sgd".
+//	 reg 		- The regularization parameter of the cost function that is
+//				  optimized. Default is 0.02 for SGD and 10 for ALS.
+//	 lr 		- The learning rate of SGD. Default is 0.01.
+//	 nFactors	- The number of latent factors. Default is 10.
+//	 nEpochs	- The number of iteration of the SGD (ALS) procedure. Default is 20.
+//	 initMean	- The Means of initial random latent factors. Default is 0.
+//	 initStdDev	- The standard deviation of initial random latent factors. Default is 0.01.
+//	 seed		- The random seed of initialization, shuffling and sampling. Default is 0.
+func (fm *FM) Fit(trainSet TrainSet) {
+	loss := fm.Params.GetString("loss", regressionLoss)
+	optimizer := fm.Params.GetString("optimizer", sgdOptimizer)
+	defaultReg := 0.02
+	if optimizer == alsOptimizer {
+		defaultReg = 10
+	}
+	reg := fm.Params.GetFloat64("reg", defaultReg)
+	lr := fm.Params.GetFloat64("lr", 0.01)
+	nFactors := fm.Params.GetInt("nFactors", 10)
+	nEpochs := fm.Params.GetInt("nEpochs", 20)
+	initMean := fm.Params.GetFloat64("initMean", 0)
+	initStdDev := fm.Params.GetFloat64("initStdDev", 0.01)
+	seed := int64(fm.Params.GetInt("seed", 0))
+	rng := rand.New(rand.NewSource(seed))
+	if loss != regressionLoss && loss != bprLoss {
+		panic("unknown loss: " + loss)
+	}
+	if optimizer != sgdOptimizer && optimizer != alsOptimizer {
+		panic("unknown optimizer: " + optimizer)
+	}
+	if loss == bprLoss && optimizer == alsOptimizer {
+		panic("BPR loss could not be optimized by ALS")
+	}
+	// 初始化参数
+	fm.Data = trainSet
+	fm.SideInfo = fm.Params.GetSideInfo("sideInfo", nil)
+	fm.indexFeatures(trainSet)
+	fm.GlobalBias = 0
+	fm.NFactors = nFactors
+	fm.Weights = make([]float64, len(fm.FeatureIndex))
+	fm.Factors = make([][]float64, len(fm.FeatureIndex))
+	for i := range fm.Factors {
+		fm.Factors[i] = newNormalVector(rng, nFactors, initMean, initStdDev)
+	}
+	// 缓存用户和物品的特征向量
+	fm.userVectors, fm.itemVectors = nil, nil
+	userVectors := make([][]FeatureValue, trainSet.UserCount)
+	for innerUserId, userId := range trainSet.OuterUserIDs {
+		userVectors[innerUserId] = fm.userVector(userId)
+	}
+	itemVectors := make([][]FeatureValue, trainSet.ItemCount)
+	for innerItemId, itemId := range trainSet.OuterItemIDs {
+		itemVectors[innerItemId] = fm.itemVector(itemId)
+	}
+	fm.userVectors, fm.itemVectors = userVectors, itemVectors
+	// 编码上下文特征
+	contexts := make([][]FeatureValue, trainSet.Length())
+	for i := range contexts {
+		if trainSet.HasContexts() {
+			contexts[i] = fm.appendFeatures(nil, trainSet.Contexts[i])
+		}
+	}
+	encode := func(innerUserId, innerItemId int, context []FeatureValue) []FeatureValue {
+		x := make([]FeatureValue, 0, len(userVectors[innerUserId])+len(itemVectors[innerItemId])+len(context))
+		x = append(x, userVectors[innerUserId]...)
+		x = append(x, itemVectors[innerItemId]...)
+		return append(x, context...)
+	}
+	rows := make([][]FeatureValue, trainSet.Length())
+	for i := range rows {
+		userId, itemId, _ := trainSet.Index(i)
+		rows[i] = encode(trainSet.ConvertUserID(userId), trainSet.ConvertItemID(itemId), contexts[i])
+	}
+	if optimizer == alsOptimizer {
+		fm.fitALS(rows, trainSet.Ratings, nEpochs, reg)
+		return
+	}
+	// 梯度缓存
+	gradWeights := make([]float64, len(fm.FeatureIndex))
+	gradFactors := newZeroMatrix(len(fm.FeatureIndex), nFactors)
+	touched := make([]bool, len(fm.FeatureIndex))
+	indices := make([]int, 0)
+	// accumulate adds scale * ∂\hat{y}(x)/∂θ to gradients
+	accumulate := func(x []FeatureValue, sums []float64, scale float64) {
+		for _, fv := range x {
+			if !touched[fv.Index] {
+				touched[fv.Index] = true
+				indices = append(indices, fv.Index)
+			}
+			gradWeights[fv.Index] += scale * fv.Value
+			for f, v := range fm.Factors[fv.Index] {
+				gradFactors[fv.Index][f] += scale * fv.Value * (sums[f] - v*fv.Value)
+			}
+		}
+	}
+	// apply updates θ += lr * (grad - reg * θ) and resets gradients
+	apply := func() {
+		for _, index := range indices {
+			fm.Weights[index] += lr * (gradWeights[index] - reg*fm.Weights[index])
+			for f, v := range fm.Factors[index] {
+				fm.Factors[index][f] += lr * (gradFactors[index][f] - reg*v)
+			}
+			gradWeights[index] = 0
+			resetZeroVector(gradFactors[index])
+			touched[index] = false
+		}
+		indices = indices[:0]
+	}
+	sums := make([]float64, nFactors)
+	negativeSums := make([]float64, nFactors)
+	sampler := NewUniformSampler(trainSet, seed)
+	// 随机梯度下降
+	for epoch := 0; epoch < nEpochs; epoch++ {
+		for _, n := range rng.Perm(trainSet.Length()) {
+			if loss == regressionLoss {
+				diff := fm.predictVector(rows[n], sums) - trainSet.Ratings[n]
+				fm.GlobalBias -= lr * diff
+				accumulate(rows[n], sums, -diff)
+				apply()
+				continue
+			}
+			// 采样 (u, i, j)
+			i := rng.Intn(trainSet.Length())
+			innerUserId := trainSet.ConvertUserID(trainSet.Users[i])
+			negatives := sampler.Sample(innerUserId, 1)
+			if len(negatives) == 0 {
+				continue
+			}
+			negative := encode(innerUserId, negatives[0], contexts[i])
+			xuij := fm.predictVector(rows[i], sums) - fm.predictVector(negative, negativeSums)
+			// 梯度系数 σ(-x_uij)
+			grad := 1 / (1 + math.Exp(xuij))
+			accumulate(rows[i], sums, grad)
+			accumulate(negative, negativeSums, -grad)
+			apply()
+		}
+	}
+}
+
+// fitALS optimizes the squared loss by coordinate descent. Each parameter
+// θ is solved in closed form with the others fixed:
+//
+//	θ = (θ Σ_i h_θ(x_i)^2 - Σ_i e_ih_θ(x_i)) / (Σ_i h_θ(x_i)^2 + λ)
+//
+// where h_θ(x) = ∂\hat{y}(x)/∂θ and e_i = \hat{y}(x_i) - y_i. Errors e_i
+// and sums Σ_j v_{jf}x_{ij} are cached and updated along with parameters.
+func (fm *FM) fitALS(rows [][]FeatureValue, ratings []float64, nEpochs int, reg float64) {
+	nFactors := fm.NFactors
+	// 按特征索引的列
+	type rowValue struct {
+		row   int
+		value float64
+	}
+	columns := make([][]rowValue, len(fm.FeatureIndex))
+	for i, x := range rows {
+		for _, fv := range x {
+			columns[fv.Index] = append(columns[fv.Index], rowValue{i, fv.Value})
+		}
+	}
+	// 缓存误差和因子和
+	errs := make([]float64, len(rows))
+	sums := newZeroMatrix(len(rows), nFactors)
+	for i, x := range rows {
+		errs[i] = fm.predictVector(x, sums[i]) - ratings[i]
+	}
+	h := make([]float64, len(rows))
+	for epoch := 0; epoch < nEpochs; epoch++ {
+		// 全局偏置
+		delta := -stat.Mean(errs, nil)
+		fm.GlobalBias += delta
+		for i := range errs {
+			errs[i] += delta
+		}
+		// 一阶权重
+		for j, column := range columns {
+			sumSquare, sumError := 0.0, 0.0
+			for _, rv := range column {
+				sumSquare += rv.value * rv.value
+				sumError += errs[rv.row] * rv.value
+			}
+			w := (fm.Weights[j]*sumSquare - sumError) / (sumSquare + reg)
+			delta := w - fm.Weights[j]
+			fm.Weights[j] = w
+			for _, rv := range column {
+				errs[rv.row] += delta * rv.value
+			}
+		}
+		// 二阶因子
+		for f := 0; f < nFactors; f++ {
+			for j, column := range columns {
+				v := fm.Factors[j][f]
+				sumSquare, sumError := 0.0, 0.0
+				for k, rv := range column {
+					h[k] = rv.value * (sums[rv.row][f] - v*rv.value)
+					sumSquare += h[k] * h[k]
+					sumError += errs[rv.row] * h[k]
+				}
+				newV := (v*sumSquare - sumError) / (sumSquare + reg)
+				delta := newV - v
+				fm.Factors[j][f] = newV
+				for k, rv := range column {
+					errs[rv.row] += delta * h[k]
+					sums[rv.row][f] += delta * rv.value
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"gonum.org/v1/gonum/stat"
+	"math"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFMWithSideInfo(t *testing.T) {
+	info, err := LoadSideInfoFromBuiltIn("ml-100k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := Parameters{"optimizer": "als", "sideInfo": info}
+	results := CrossValidate(NewFM(nil), LoadDataFromBuiltIn("ml-100k"), []Evaluator{RMSE},
+		NewKFoldSplitter(5, 0), params, runtime.NumCPU())
+	if rmse := stat.Mean(results[0].Tests, nil); rmse > 0.915+estimatorEpsilon {
+		t.Fatalf("RMSE(%.3f) > %.3f+%.3f", rmse, 0.915, estimatorEpsilon)
+	}
+}
+
+func TestFMWithBPR(t *testing.T) {
+	dataSet := LoadImplicitDataFromBuiltIn("ml-100k")
+	params := Parameters{"loss": "bpr", "lr": 0.05, "nEpochs": 50}
+	results := CrossValidate(NewFM(nil), dataSet, []Evaluator{NewSampledAUC(dataSet, 100, 0)},
+		NewKFoldSplitter(5, 0), params, runtime.NumCPU())
+	if auc := stat.Mean(results[0].Tests, nil); auc < 0.92-estimatorEpsilon {
+		t.Fatalf("AUC(%.3f) < %.3f-%.3f", auc, 0.92, estimatorEpsilon)
+	}
+}
+
+func TestFMWithContext(t *testing.T) {
+	// Ratings are 5 on weekends and 1 on weekdays, regardless of users and items.
+	users := []int{1, 1, 2, 2, 3, 3, 4, 4}
+	items := []int{1, 2, 1, 2, 1, 2, 1, 2}
+	ratings := make([]float64, len(users))
+	contexts := make([]Features, len(users))
+	for i := range users {
+		if (users[i]+items[i])%2 == 0 {
+			ratings[i] = 5
+			contexts[i] = Features{Categorical: []string{"day=weekend"}}
+		} else {
+			ratings[i] = 1
+			contexts[i] = Features{Categorical: []string{"day=weekday"}}
+		}
+	}
+	dataSet := NewRawSet(users, items, ratings)
+	dataSet.Contexts = contexts
+	fm := NewFM(Parameters{"optimizer": "als", "reg": 0.01})
+	fm.Fit(NewTrainSet(dataSet))
+	weekend := fm.PredictWithContext(1, 2, Features{Categorical: []string{"day=weekend"}})
+	weekday := fm.PredictWithContext(1, 1, Features{Categorical: []string{"day=weekday"}})
+	if math.Abs(weekend-5) > 0.1 || math.Abs(weekday-1) > 0.1 {
+		t.Fatalf("Predictions (%.3f, %.3f) != (%.3f, %.3f)", weekend, weekday, 5.0, 1.0)
+	}
+	// Contexts survive splitting
+	predictions := fm.PredictDataSet(dataSet.SubSet([]int{0, 1}))
+	if math.Abs(predictions[0]-5) > 0.1 || math.Abs(predictions[1]-1) > 0.1 {
+		t.Fatalf("Predictions (%v) != (%v)", predictions, []float64{5, 1})
+	}
+}
+
+func TestFMSave(t *testing.T) {
+	info := NewSideInfo()
+	info.Items[1] = &ItemInfo{ID: 1, Genres: []string{"Action"}}
+	info.Items[3] = &ItemInfo{ID: 3, Genres: []string{"Action"}}
+	fm1 := NewFM(Parameters{"sideInfo": info, "nFactors": 4})
+	// Predict before fitting
+	if prediction := fm1.Predict(1, 1); prediction != 0 {
+		t.Fatalf("Prediction before fitting (%.3f) != %.3f", prediction, 0.0)
+	}
+	fm1.Fit(NewTrainSet(NewRawSet([]int{1, 1, 2}, []int{1, 2, 2}, []float64{5, 1, 2})))
+	// Side information is saved along with the model
+	fileName := filepath.Join(tempDir, "/fm.m")
+	if err := Save(fileName, fm1); err != nil {
+		t.Fatal(err)
+	}
+	fm2 := NewFM(nil)
+	if err := Load(fileName, fm2); err != nil {
+		t.Fatal(err)
+	}
+	if fm2.Params.GetSideInfo("sideInfo", nil) == nil {
+		t.Fatal("Side information is not restored")
+	}
+	// Unknown item 3 is predicted by its genre
+	if p1, p2 := fm1.Predict(2, 3), fm2.Predict(2, 3); p1 != p2 {
+		t.Fatalf("Prediction of the restored model (%.3f) != %.3f", p2, p1)
+	}
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"math"
+)
+
+// NewImplicitSet creates a data set of implicit feedback. Counts could be
+// nil, which means every interaction counts 1.
+func NewImplicitSet(users, items []int, counts []float64) DataSet {
+	if counts == nil {
+		counts = make([]float64, len(users))
+		for i := range counts {
+			counts[i] = 1
+		}
+	}
+	set := NewRawSet(users, items, counts)
+	set.Implicit = true
+	return set
+}
+
+// ToImplicit converts explicit ratings to implicit feedback. Ratings not
+// less than the threshold become interactions with count 1, and the others
+// are dropped.
+func (d *DataSet) ToImplicit(threshold float64) DataSet {
+	indices := make([]int, 0)
+	for i, rating := range d.Ratings {
+		if rating >= threshold {
+			indices = append(indices, i)
+		}
+	}
+	set := d.SubSet(indices)
+	for i := range set.Ratings {
+		set.Ratings[i] = 1
+	}
+	set.Implicit = true
+	return set
+}
+
+// mergeImplicit merges duplicated <user, item> pairs by summing counts and
+// keeping the latest timestamp. Pairs keep the order of first appearance.
+func mergeImplicit(d DataSet) DataSet {
+	type pair struct{ user, item int }
+	positions := make(map[pair]int)
+	indices := make([]int, 0)
+	counts := make([]float64, 0)
+	timestamps := make([]int64, 0)
+	for i := 0; i < d.Length(); i++ {
+		key := pair{d.Users[i], d.Items[i]}
+		if pos, exist := positions[key]; exist {
+			counts[pos] += d.Ratings[i]
+			if d.HasTimestamps() && d.Timestamps[i] > timestamps[pos] {
+				timestamps[pos] = d.Timestamps[i]
+			}
+			continue
+		}
+		positions[key] = len(indices)
+		indices = append(indices, i)
+		counts = append(counts, d.Ratings[i])
+		if d.HasTimestamps() {
+			timestamps = append(timestamps, d.Timestamps[i])
+		}
+	}
+	set := d.SubSet(indices)
+	set.Ratings = counts
+	if d.HasTimestamps() {
+		set.Timestamps = timestamps
+	}
+	set.Implicit = true
+	return set
+}
+
+// Confidence converts an interaction count r_ui to a confidence weight c_ui.
+type Confidence func(count float64) float64
+
+// LinearConfidence creates the linear confidence (Hu, Koren and Volinsky):
+//
+//	c_ui = 1 + α r_ui
+func LinearConfidence(alpha float64) Confidence {
+	return func(count float64) float64 {
+		return 1 + alpha*count
+	}
+}
+
+// LogConfidence creates the logarithmic confidence (Hu, Koren and Volinsky):
+//
+//	c_ui = 1 + α log(1 + r_ui/ε)
+func LogConfidence(alpha float64, epsilon float64) Confidence {
+	return func(count float64) float64 {
+		return 1 + alpha*math.Log(1+count/epsilon)
+	}
+}
+
+// Confidences computes confidence weights of all interactions.
+func (d *DataSet) Confidences(confidence Confidence) []float64 {
+	weights := make([]float64, d.Length())
+	for i, count := range d.Ratings {
+		weights[i] = confidence(count)
+	}
+	return weights
+}
+
+// ImplicitEstimator is implemented by estimators that could be trained on
+// implicit feedback.
+type ImplicitEstimator interface {
+	Estimator
+	SupportImplicit() bool
+}
+
+// IsImplicitEstimator checks whether an estimator could be trained on
+// implicit feedback. Rating predictors (e.g. SVD and KNN) are not.
+func IsImplicitEstimator(estimator Estimator) bool {
+	if implicitEstimator, ok := estimator.(ImplicitEstimator); ok {
+		return implicitEstimator.SupportImplicit()
+	}
+	return false
+}
+
+// IsImplicitEvaluator checks whether an evaluator is valid for implicit
+// feedback. Ranking evaluators (AUC, Precision, NDCG, etc.) are valid since
+// they only depend on the order of predictions, while RMSE and MAE are not.
+func IsImplicitEvaluator(evaluator Evaluator) bool {
+	return evaluator.Maximize()
+}
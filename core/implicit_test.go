@@ -0,0 +1,64 @@
+package core
+
+import (
+	"gonum.org/v1/gonum/floats"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadImplicitData(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "clicks.csv")
+	content := "1,10\n1,20,3\n2,10\n1,10,2\n"
+	if err := os.WriteFile(fileName, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, _, err := TryLoadImplicitDataFromFile(fileName, ",", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !data.Implicit {
+		t.Fatal("data set should be implicit")
+	}
+	// Duplicated pairs are merged
+	if !EqualInt(data.Users, []int{1, 1, 2}) || !EqualInt(data.Items, []int{10, 20, 10}) {
+		t.Fatalf("Pairs (%v, %v) don't match", data.Users, data.Items)
+	}
+	if !floats.Equal(data.Ratings, []float64{3, 3, 1}) {
+		t.Fatalf("Counts (%v) don't match", data.Ratings)
+	}
+	// Survive splitting
+	trainSet, testSet := data.Split(0.3, 0)
+	if !trainSet.Implicit || !testSet.Implicit {
+		t.Fatal("implicit flag is lost after splitting")
+	}
+}
+
+func TestToImplicit(t *testing.T) {
+	data := NewRawSet([]int{1, 1, 2}, []int{1, 2, 1}, []float64{5, 2, 4})
+	implicit := data.ToImplicit(4)
+	if !implicit.Implicit || !EqualInt(implicit.Users, []int{1, 2}) || !floats.Equal(implicit.Ratings, []float64{1, 1}) {
+		t.Fatalf("Implicit data (%v, %v) doesn't match", implicit.Users, implicit.Ratings)
+	}
+}
+
+func TestConfidence(t *testing.T) {
+	data := NewImplicitSet([]int{1, 2}, []int{1, 1}, []float64{1, 3})
+	if weights := data.Confidences(LinearConfidence(40)); !floats.Equal(weights, []float64{41, 121}) {
+		t.Fatalf("Linear confidences (%v) don't match", weights)
+	}
+	weights := data.Confidences(LogConfidence(2, 1))
+	if math.Abs(weights[1]-(1+2*math.Log(4))) > epsilon {
+		t.Fatalf("Log confidence (%.3f) doesn't match", weights[1])
+	}
+}
+
+func TestImplicitFlags(t *testing.T) {
+	if IsImplicitEstimator(NewSVD(nil)) {
+		t.Fatal("SVD should not support implicit feedback")
+	}
+	if IsImplicitEvaluator(RMSE) || !IsImplicitEvaluator(NewNDCG(10, 1)) {
+		t.Fatal("implicit flags of evaluators don't match")
+	}
+}
@@ -1,10 +1,12 @@
 package core
 
 import (
+	"container/heap"
 	"math"
 	"runtime"
 	"sort"
 	"sync"
+	"unsafe"
 )
 
 const (
@@ -14,37 +16,93 @@ const (
 	baseline = "baseline"
 )
 
+// KNN is the neighborhood-based collaborative filtering. Only the top
+// similar neighbors of each user (item) are stored, sorted by inner ID, so
+// that the neighbors who rated an item (the items rated by a user) are found
+// by merging two sorted lists in prediction.
 type KNN struct {
 	Base
 	KNNType      string
 	GlobalMean   float64
-	Sims         [][]float64
+	Neighbors    [][]IDScore // Top similar users (items) of each user (item), sorted by inner ID
 	LeftRatings  [][]IDRating
-	RightRatings [][]IDRating
-	Means        []float64 // Centered KNN :user(item) Means
-	StdDevs      []float64 // KNN with Z Score: user (item) standard deviation
-	Bias         []float64 // KNN BaseLine :Bias
+	RightRatings [][]IDRating // Ratings of items (users), sorted by inner ID
+	Means        []float64    // Centered KNN :user(item) Means
+	StdDevs      []float64    // KNN with Z Score: user (item) standard deviation
+	Bias         []float64    // KNN BaseLine :Bias
+	// Vectors of items (users) in user (item) based KNN for similar queries
+	transposed      []SortedIdRatings
+	transposedSim   Sim
+	transposedMutex sync.Mutex
 }
-type CandidateSet struct {
-	similarities []float64
-	candidates   []IDRating // 改为 candidates
+
+// defaultMaxMemory is the default memory budget of stored neighbors in
+// bytes. All neighbors fit into it for small data sets (e.g. ml-100k), while
+// about 480 neighbors of each user are stored for ml-20m.
+const defaultMaxMemory = 1 << 30
+
+// neighborSize is the memory of a stored neighbor in bytes.
+const neighborSize = int(unsafe.Sizeof(IDScore{}))
+
+// neighborRating is a rating of a neighbor with the similarity.
+type neighborRating struct {
+	IDRating
+	Sim float64
+}
+
+// neighborRatings is a min-heap of neighbor ratings by similarity, which
+// keeps the top k similar neighbors in prediction.
+type neighborRatings []neighborRating
+
+func (n neighborRatings) Len() int {
+	return len(n)
 }
 
-func NewCandidateSet(sim []float64, candidates []IDRating) *CandidateSet {
-	neighbors := &CandidateSet{}
-	neighbors.similarities = sim
-	neighbors.candidates = candidates
-	return neighbors
+func (n neighborRatings) Less(i, j int) bool {
+	return n[i].Sim < n[j].Sim
 }
-func (n *CandidateSet) Len() int {
-	return len(n.candidates)
+
+func (n neighborRatings) Swap(i, j int) {
+	n[i], n[j] = n[j], n[i]
 }
 
-func (n *CandidateSet) Less(i, j int) bool {
-	return n.similarities[n.candidates[i].ID] > n.similarities[n.candidates[j].ID]
+func (n *neighborRatings) Push(x interface{}) {
+	*n = append(*n, x.(neighborRating))
 }
-func (n *CandidateSet) Swap(i, j int) {
-	n.candidates[i], n.candidates[j] = n.candidates[j], n.candidates[i]
+
+func (n *neighborRatings) Pop() interface{} {
+	old := *n
+	x := old[len(old)-1]
+	*n = old[:len(old)-1]
+	return x
+}
+
+// neighborHeap is a min-heap of neighbors by similarity, which keeps the
+// top similar neighbors.
+type neighborHeap []IDScore
+
+func (h neighborHeap) Len() int {
+	return len(h)
+}
+
+func (h neighborHeap) Less(i, j int) bool {
+	return h[i].Score < h[j].Score
+}
+
+func (h neighborHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+
+func (h *neighborHeap) Push(x interface{}) {
+	*h = append(*h, x.(IDScore))
+}
+
+func (h *neighborHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
 }
 
 func NewKNN(params Parameters) *KNN {
@@ -79,6 +137,7 @@ func (K *KNN) Predict(userID int, itemID int) float64 {
 	userBased := K.Params.GetBool("userBased", true)
 	k := K.Params.GetInt("k", 40)
 	minK := K.Params.GetInt("mink", 1)
+	positiveOnly := K.Params.GetBool("positiveOnly", false)
 	// 基于用户 or 物品 ？
 	var leftID, rightID int
 	if userBased {
@@ -89,34 +148,16 @@ func (K *KNN) Predict(userID int, itemID int) float64 {
 	if leftID == newID || rightID == newID {
 		return K.GlobalMean
 	}
-	// 获取用户（物品）有交互的 物品（用户）
-	candidates := make([]IDRating, 0)
-
-	for _, ir := range K.RightRatings[rightID] {
-		if !math.IsNaN(K.Sims[leftID][ir.ID]) {
-			candidates = append(candidates, ir)
-		}
-	}
-
+	candidates, count := K.topNeighbors(leftID, rightID, k, positiveOnly)
 	// 如果用户（物品） 的数量小于最小值 k。 则使用全局平均直作为预测结果
-	if len(candidates) <= minK {
+	if count <= minK {
 		return K.GlobalMean
 	}
-
-	// 排序 通过相似度排序
-	candidateSet := NewCandidateSet(K.Sims[leftID], candidates)
-	sort.Sort(candidateSet)
-
-	// 控制User邻居数量
-	numNeighbors := k
-	if numNeighbors > candidateSet.Len() {
-		numNeighbors = candidateSet.Len()
-	}
 	// 预测分数 根据带权平均值
 	weightSum := 0.0
 	weightRating := 0.0
-	for _, or := range candidateSet.candidates[0:numNeighbors] {
-		weightSum += K.Sims[leftID][or.ID]
+	for _, or := range candidates {
+		weightSum += or.Sim
 		// （以基于用户的角度）用户与候选人相似度 * 候选人对该物品的评分
 		rating := or.Rating
 		if K.KNNType == centered {
@@ -126,7 +167,7 @@ func (K *KNN) Predict(userID int, itemID int) float64 {
 		} else if K.KNNType == baseline {
 			rating -= K.Bias[or.ID]
 		}
-		weightRating += K.Sims[leftID][or.ID] * rating
+		weightRating += or.Sim * rating
 	}
 	prediction := weightRating / weightSum
 	if K.KNNType == centered {
@@ -140,25 +181,74 @@ func (K *KNN) Predict(userID int, itemID int) float64 {
 	return prediction
 }
 
+// topNeighbors merges the neighbors of a user (item) with the users (items)
+// who rated an item (the items rated by a user), and keeps the top k similar
+// ones in a min-heap. The number of merged neighbors is returned as well.
+func (K *KNN) topNeighbors(leftID, rightID, k int, positiveOnly bool) (neighborRatings, int) {
+	neighbors := K.Neighbors[leftID]
+	candidates := make(neighborRatings, 0)
+	count, ptr := 0, 0
+	for _, ir := range K.RightRatings[rightID] {
+		for ptr < len(neighbors) && neighbors[ptr].ID < ir.ID {
+			ptr++
+		}
+		if ptr < len(neighbors) && neighbors[ptr].ID == ir.ID {
+			// 可选：忽略非正相似度的邻居
+			if positiveOnly && neighbors[ptr].Score <= 0 {
+				continue
+			}
+			count++
+			if len(candidates) < k {
+				heap.Push(&candidates, neighborRating{ir, neighbors[ptr].Score})
+			} else if k > 0 && neighbors[ptr].Score > candidates[0].Sim {
+				candidates[0] = neighborRating{ir, neighbors[ptr].Score}
+				heap.Fix(&candidates, 0)
+			}
+		}
+	}
+	return candidates, count
+}
+
+// Fit a KNN model. Similarities between users (items) are computed in
+// parallel and shrunk by their supports, and only the top nNeighbors
+// similar neighbors of each user (item) are stored. If maxMemory is set,
+// nNeighbors is reduced so that neighbors fit into maxMemory bytes.
+// Parameters:
+//
+//	 sim		- The similarity function (Sim or SimBuilder). Default is MSD.
+//	 minSupport	- The min number of co-rated items (users) of neighbors. Default is 1.
+//	 shrinkage	- The shrinkage of similarities (see Shrink). Default is 0.
+//	 userBased	- User based or item based. Default is true.
+//	 k			- The max number of neighbors in prediction. Default is 40.
+//	 mink		- The min number of neighbors in prediction. Default is 1.
+//	 positiveOnly	- Only use neighbors with positive similarities in
+//				  prediction. Default is false.
+//	 nNeighbors	- The number of neighbors stored for each user (item). All
+//				  neighbors are stored if not positive. Default is 0.
+//	 maxMemory	- The memory budget of neighbors in bytes. Not limited if
+//				  not positive. Default is 1 GiB.
+//	 nJobs		- The number of goroutines. Default is the number of CPUs.
 func (K *KNN) Fit(trainSet TrainSet) {
 	// Setup parameters
-	sim := K.Params.GetSim("sim", MSD)
+	simBuilder := K.Params.GetSimBuilder("sim", NewSimBuilder(MSD))
+	minSupport := K.Params.GetInt("minSupport", 1)
+	shrinkage := K.Params.GetFloat64("shrinkage", 0)
 	userBased := K.Params.GetBool("userBased", true)
+	nNeighbors := K.Params.GetInt("nNeighbors", 0)
+	maxMemory := K.Params.GetInt("maxMemory", defaultMaxMemory)
 	//  nJobs
 	nJobs := K.Params.GetInt("nJobs", runtime.NumCPU())
-	//nJobs := runtime.NumCPU()
 	K.Data = trainSet
+	K.transposed, K.transposedSim = nil, nil
 	// 设置全局平均值为新的用户（物品）
 	K.GlobalMean = trainSet.GlobalMean
 	// 获取用户（物品） 评分
 	if userBased {
 		K.LeftRatings = trainSet.UserRatings()
-		K.RightRatings = trainSet.ItemRatings()
-		K.Sims = newNanMatrix(trainSet.UserCount, trainSet.UserCount)
+		K.RightRatings = sortedRatings(trainSet.ItemRatings())
 	} else {
 		K.LeftRatings = trainSet.ItemRatings()
-		K.RightRatings = trainSet.UserRatings()
-		K.Sims = newNanMatrix(trainSet.ItemCount, trainSet.ItemCount)
+		K.RightRatings = sortedRatings(trainSet.UserRatings())
 	}
 	// 获取 user（item）的平均值
 	if K.KNNType == centered || K.KNNType == zScore {
@@ -185,33 +275,109 @@ func (K *KNN) Fit(trainSet TrainSet) {
 			K.Bias = baseLine.itemBias
 		}
 	}
-	// 计算全部矩阵 比 只计算上三角矩阵块约0.2s， 利用缓存友好性
-	// 计算用户的两两相似性
-	sortedLeftRatings := sorts(K.LeftRatings)
+	// 准备用于计算相似度的向量
+	sortedLeftRatings, sim := simBuilder(trainSet, userBased)
+	sim = Shrink(sim, minSupport, shrinkage)
+	// 计算内存预算下的邻居数量
 	length := len(sortedLeftRatings)
-	var wg sync.WaitGroup
-	wg.Add(nJobs)
-	for j := 0; j < nJobs; j++ {
-		go func(jobID int) {
-			begin := length * jobID / nJobs
-			end := length * (jobID + 1) / nJobs
-
-			for iID := begin; iID < end; iID++ {
-				iRatings := sortedLeftRatings[iID]
-				for jID, jRatings := range sortedLeftRatings {
-					if iID != jID {
-						if math.IsNaN(K.Sims[iID][jID]) {
-							ret := sim(iRatings, jRatings)
-							if !math.IsNaN(ret) {
-								K.Sims[iID][jID] = ret
-								K.Sims[jID][iID] = ret
-							}
-						}
-					}
+	if maxMemory > 0 && length > 0 {
+		limit := maxMemory / (length * neighborSize)
+		if nNeighbors <= 0 || limit < nNeighbors {
+			nNeighbors = limit
+		}
+	} else if nNeighbors <= 0 {
+		nNeighbors = length
+	}
+	// 计算用户的两两相似性，只保留最相似的邻居
+	K.Neighbors = make([][]IDScore, length)
+	parallel(length, nJobs, func(begin, end int) {
+		for iID := begin; iID < end; iID++ {
+			neighbors := make(neighborHeap, 0, nNeighbors)
+			for jID, jRatings := range sortedLeftRatings {
+				if iID == jID {
+					continue
+				}
+				ret := sim(sortedLeftRatings[iID], jRatings)
+				if math.IsNaN(ret) {
+					continue
+				}
+				if len(neighbors) < nNeighbors {
+					heap.Push(&neighbors, IDScore{jID, ret})
+				} else if nNeighbors > 0 && ret > neighbors[0].Score {
+					neighbors[0] = IDScore{jID, ret}
+					heap.Fix(&neighbors, 0)
 				}
 			}
-			wg.Done()
-		}(j)
+			sort.Slice(neighbors, func(i, j int) bool {
+				return neighbors[i].ID < neighbors[j].ID
+			})
+			K.Neighbors[iID] = neighbors
+		}
+	})
+}
+
+// SimilarUsers returns stored neighbors of a user for user based KNN. For
+// item based KNN, similarities between users are computed on demand.
+func (K *KNN) SimilarUsers(userId, n int) []IDScore {
+	innerUserID := K.Data.ConvertUserID(userId)
+	if !K.Params.GetBool("userBased", true) {
+		return K.similarOnDemand(innerUserID, K.Data.OuterUserIDs, n)
+	}
+	return K.similar(innerUserID, K.Data.OuterUserIDs, n)
+}
+
+// SimilarItems returns stored neighbors of an item for item based KNN. For
+// user based KNN, similarities between items are computed on demand.
+func (K *KNN) SimilarItems(itemId, n int) []IDScore {
+	innerItemID := K.Data.ConvertItemID(itemId)
+	if K.Params.GetBool("userBased", true) {
+		return K.similarOnDemand(innerItemID, K.Data.OuterItemIDs, n)
+	}
+	return K.similar(innerItemID, K.Data.OuterItemIDs, n)
+}
+
+func (K *KNN) similar(innerID int, ids []int, n int) []IDScore {
+	if innerID == newID {
+		return nil
+	}
+	// 转换为外部 ID
+	scores := make([]IDScore, len(K.Neighbors[innerID]))
+	for i, neighbor := range K.Neighbors[innerID] {
+		scores[i] = IDScore{ids[neighbor.ID], neighbor.Score}
+	}
+	return topIDScores(scores, n)
+}
+
+// similarOnDemand compares an item (user) with all items (users) in user
+// (item) based KNN, by the configured similarity, minSupport and shrinkage.
+func (K *KNN) similarOnDemand(innerID int, ids []int, n int) []IDScore {
+	if innerID == newID {
+		return nil
+	}
+	vectors, sim := K.transposedVectors()
+	scores := make([]IDScore, 0, len(vectors))
+	for j := range vectors {
+		if j == innerID {
+			continue
+		}
+		if ret := sim(vectors[innerID], vectors[j]); !math.IsNaN(ret) {
+			scores = append(scores, IDScore{ids[j], ret})
+		}
+	}
+	return topIDScores(scores, n)
+}
+
+// transposedVectors builds vectors of items (users) in user (item) based KNN
+// at the first query, and caches them until the model is fitted again.
+func (K *KNN) transposedVectors() ([]SortedIdRatings, Sim) {
+	K.transposedMutex.Lock()
+	defer K.transposedMutex.Unlock()
+	if K.transposed == nil {
+		simBuilder := K.Params.GetSimBuilder("sim", NewSimBuilder(MSD))
+		minSupport := K.Params.GetInt("minSupport", 1)
+		shrinkage := K.Params.GetFloat64("shrinkage", 0)
+		vectors, sim := simBuilder(K.Data, !K.Params.GetBool("userBased", true))
+		K.transposed, K.transposedSim = vectors, Shrink(sim, minSupport, shrinkage)
 	}
-	wg.Wait()
+	return K.transposed, K.transposedSim
 }
@@ -0,0 +1,93 @@
+package core
+
+// IDScore is an outer ID with its score.
+type IDScore struct {
+	ID    int
+	Score float64
+}
+
+// RecommendOptions controls which items are ranked by Recommend.
+type RecommendOptions struct {
+	IncludeRated bool  // Keep items the user has rated in the training set
+	Candidates   []int // Only rank these items (outer IDs). All items if empty.
+	Excludes     []int // Never recommend these items (outer IDs)
+}
+
+// Recommender generates top-N item lists for users.
+type Recommender interface {
+	Recommend(userId, n int, options *RecommendOptions) []IDScore
+}
+
+// NewRecommender wraps an estimator as a Recommender.
+func NewRecommender(estimator Estimator) Recommender {
+	return &estimatorRecommender{estimator}
+}
+
+type estimatorRecommender struct {
+	estimator Estimator
+}
+
+func (r *estimatorRecommender) Recommend(userId, n int, options *RecommendOptions) []IDScore {
+	return Recommend(r.estimator, userId, n, options)
+}
+
+// Recommend ranks items for a user by the predictions of a fitted estimator.
+// Items come from the training set of the estimator unless candidates are
+// given. Items already rated by the user are excluded by default. The top n
+// items are returned in descending order of score, or all of them if n <= 0.
+func Recommend(estimator Estimator, userId, n int, options *RecommendOptions) []IDScore {
+	if options == nil {
+		options = &RecommendOptions{}
+	}
+	trainSet := estimator.GetTrainSet()
+	// Collect excluded items
+	excludes := make(Set)
+	for _, itemId := range options.Excludes {
+		excludes[itemId] = nil
+	}
+	rated := make(Set)
+	if innerUserId := trainSet.ConvertUserID(userId); innerUserId != newID && !options.IncludeRated {
+		for _, ir := range trainSet.UserRatings()[innerUserId] {
+			rated[ir.ID] = nil
+		}
+	}
+	// Collect candidates
+	candidates := options.Candidates
+	if len(candidates) == 0 {
+		candidates = trainSet.OuterItemIDs
+	}
+	// Score candidates
+	scores := make([]IDScore, 0, len(candidates))
+	for _, itemId := range candidates {
+		if _, exist := excludes[itemId]; exist {
+			continue
+		}
+		if innerItemId := trainSet.ConvertItemID(itemId); innerItemId != newID {
+			if _, exist := rated[innerItemId]; exist {
+				continue
+			}
+		}
+		// Avoid duplicated candidates
+		excludes[itemId] = nil
+		scores = append(scores, IDScore{ID: itemId, Score: estimator.Predict(userId, itemId)})
+	}
+	return topIDScores(scores, n)
+}
+
+// sortedIDScores sorts by score in descending order. Ties are broken by ID.
+type sortedIDScores []IDScore
+
+func (s sortedIDScores) Len() int {
+	return len(s)
+}
+
+func (s sortedIDScores) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sortedIDScores) Less(i, j int) bool {
+	if s[i].Score != s[j].Score {
+		return s[i].Score > s[j].Score
+	}
+	return s[i].ID < s[j].ID
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestRecommend(t *testing.T) {
+	baseLine := NewBaseLine(nil)
+	trainSet := NewTrainSet(NewRawSet([]int{1, 1, 2, 2, 2, 3}, []int{10, 20, 10, 30, 40, 40}, []float64{5, 1, 5, 3, 4, 4}))
+	baseLine.Fit(trainSet)
+	// Rated items are excluded by default
+	items := Recommend(baseLine, 1, 0, nil)
+	if len(items) != 2 {
+		t.Fatalf("Number of items (%d) != %d", len(items), 2)
+	}
+	for _, item := range items {
+		if item.ID == 10 || item.ID == 20 {
+			t.Fatalf("Rated item %d is recommended", item.ID)
+		}
+	}
+	// Items are ranked by scores
+	for i := 1; i < len(items); i++ {
+		if items[i-1].Score < items[i].Score {
+			t.Fatalf("Items are not ranked: %v", items)
+		}
+	}
+	if items[0].ID != 40 {
+		t.Fatalf("Top item (%d) != %d", items[0].ID, 40)
+	}
+	// Rated items are included by option
+	items = Recommend(baseLine, 1, 0, &RecommendOptions{IncludeRated: true})
+	if len(items) != 4 {
+		t.Fatalf("Number of items (%d) != %d", len(items), 4)
+	}
+	// Top n
+	items = Recommend(baseLine, 1, 1, &RecommendOptions{IncludeRated: true})
+	if len(items) != 1 || items[0].ID != 10 {
+		t.Fatalf("Top item (%v) != %d", items, 10)
+	}
+}
+
+func TestRecommendCandidates(t *testing.T) {
+	baseLine := NewBaseLine(nil)
+	trainSet := NewTrainSet(NewRawSet([]int{1, 1, 2, 2, 2, 3}, []int{10, 20, 10, 30, 40, 40}, []float64{5, 1, 5, 3, 4, 4}))
+	baseLine.Fit(trainSet)
+	items := NewRecommender(baseLine).Recommend(3, 0, &RecommendOptions{
+		Candidates: []int{10, 20, 30, 40, 50},
+		Excludes:   []int{20},
+	})
+	expect := []int{10, 30, 50}
+	if len(items) != len(expect) {
+		t.Fatalf("Number of items (%d) != %d", len(items), len(expect))
+	}
+	found := make(Set)
+	for _, item := range items {
+		found[item.ID] = nil
+	}
+	for _, itemId := range expect {
+		if _, exist := found[itemId]; !exist {
+			t.Fatalf("Item %d is not recommended", itemId)
+		}
+	}
+}
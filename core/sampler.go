@@ -0,0 +1,217 @@
+package core
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// NegativeSampler samples items that a user has not interacted with in a
+// train set. IDs are inner IDs of the train set. Samplers own seeded
+// random generators, so they produce the same samples for the same seed,
+// but they are not safe for concurrent use.
+type NegativeSampler interface {
+	// Sample draws n negative items for a user. Fewer items are returned if
+	// the user has interacted with all items.
+	Sample(innerUserId int, n int) []int
+}
+
+// negativeBase holds positive items of users shared by samplers.
+type negativeBase struct {
+	rng       *rand.Rand
+	itemCount int
+	positives []Set
+}
+
+func newNegativeBase(trainSet TrainSet, seed int64) negativeBase {
+	base := negativeBase{
+		rng:       rand.New(rand.NewSource(seed)),
+		itemCount: trainSet.ItemCount,
+		positives: make([]Set, trainSet.UserCount),
+	}
+	for innerUserId, irs := range trainSet.UserRatings() {
+		base.positives[innerUserId] = make(Set)
+		for _, ir := range irs {
+			base.positives[innerUserId][ir.ID] = nil
+		}
+	}
+	return base
+}
+
+// sample draws n negative items by rejecting positive items from draw.
+func (base *negativeBase) sample(innerUserId int, n int, draw func() int) []int {
+	positives := base.positives[innerUserId]
+	if len(positives) >= base.itemCount {
+		return []int{}
+	}
+	negatives := make([]int, 0, n)
+	for len(negatives) < n {
+		innerItemId := draw()
+		if _, exist := positives[innerItemId]; !exist {
+			negatives = append(negatives, innerItemId)
+		}
+	}
+	return negatives
+}
+
+// UniformSampler samples negative items uniformly.
+type UniformSampler struct {
+	negativeBase
+}
+
+func NewUniformSampler(trainSet TrainSet, seed int64) *UniformSampler {
+	return &UniformSampler{newNegativeBase(trainSet, seed)}
+}
+
+func (s *UniformSampler) Sample(innerUserId int, n int) []int {
+	return s.sample(innerUserId, n, func() int {
+		return s.rng.Intn(s.itemCount)
+	})
+}
+
+// PopularitySampler samples negative items with probabilities proportional
+// to their popularity, i.e. count^alpha. Alpha is 0.75 in word2vec, 1 for
+// sampling by popularity and 0 for sampling uniformly.
+type PopularitySampler struct {
+	negativeBase
+	cumulative []float64
+}
+
+func NewPopularitySampler(trainSet TrainSet, alpha float64, seed int64) *PopularitySampler {
+	sampler := &PopularitySampler{negativeBase: newNegativeBase(trainSet, seed)}
+	sampler.cumulative = make([]float64, trainSet.ItemCount)
+	sum := 0.0
+	for innerItemId, irs := range trainSet.ItemRatings() {
+		sum += math.Pow(float64(len(irs)), alpha)
+		sampler.cumulative[innerItemId] = sum
+	}
+	return sampler
+}
+
+func (s *PopularitySampler) Sample(innerUserId int, n int) []int {
+	total := s.cumulative[len(s.cumulative)-1]
+	return s.sample(innerUserId, n, func() int {
+		target := s.rng.Float64() * total
+		return sort.Search(len(s.cumulative), func(i int) bool {
+			return s.cumulative[i] > target
+		})
+	})
+}
+
+// HardSampler samples hard negative items by a scoring model. For each
+// negative item, nCandidates items are drawn uniformly and the one with
+// the highest score is chosen.
+type HardSampler struct {
+	UniformSampler
+	estimator   Estimator
+	nCandidates int
+	trainSet    TrainSet
+}
+
+func NewHardSampler(trainSet TrainSet, estimator Estimator, nCandidates int, seed int64) *HardSampler {
+	return &HardSampler{
+		UniformSampler: *NewUniformSampler(trainSet, seed),
+		estimator:      estimator,
+		nCandidates:    nCandidates,
+		trainSet:       trainSet,
+	}
+}
+
+func (s *HardSampler) Sample(innerUserId int, n int) []int {
+	userId := s.trainSet.OuterUserID(innerUserId)
+	negatives := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		candidates := s.UniformSampler.Sample(innerUserId, s.nCandidates)
+		if len(candidates) == 0 {
+			break
+		}
+		best, bestScore := candidates[0], math.Inf(-1)
+		for _, innerItemId := range candidates {
+			score := s.estimator.Predict(userId, s.trainSet.OuterItemID(innerItemId))
+			if score > bestScore {
+				best, bestScore = innerItemId, score
+			}
+		}
+		negatives = append(negatives, best)
+	}
+	return negatives
+}
+
+/* Sampled evaluation */
+
+// newSampledEvaluator creates an evaluator that ranks each positive item in
+// the test set against nNegatives items sampled uniformly from items not
+// rated by the user in the full set. The metric is computed from the number
+// of negative items scored not lower than the positive item, averaged over
+// positive items of each user and then over users like NewAUC.
+func newSampledEvaluator(fullSet DataSet, nNegatives int, seed int64, metric func(higher, nNegatives int) float64) RankingEvaluator {
+	full := NewTrainSet(fullSet)
+	return RankingEvaluator(func(estimator Estimator, testSet DataSet) float64 {
+		sampler := NewUniformSampler(full, seed)
+		test := NewTrainSet(testSet)
+		userSum, userCount := 0.0, 0.0
+		for innerUserIdTest, testRatings := range test.UserRatings() {
+			userId := test.OuterUserID(innerUserIdTest)
+			innerUserIdFull := full.ConvertUserID(userId)
+			if innerUserIdFull == newID {
+				continue
+			}
+			sum, count := 0.0, 0.0
+			for _, testRating := range testRatings {
+				negatives := sampler.Sample(innerUserIdFull, nNegatives)
+				if len(negatives) == 0 {
+					continue
+				}
+				positiveScore := estimator.Predict(userId, test.OuterItemID(testRating.ID))
+				higher := 0
+				for _, innerItemId := range negatives {
+					if estimator.Predict(userId, full.OuterItemID(innerItemId)) >= positiveScore {
+						higher++
+					}
+				}
+				sum += metric(higher, len(negatives))
+				count++
+			}
+			if count > 0 {
+				userSum += sum / count
+				userCount++
+			}
+		}
+		if userCount > 0 {
+			return userSum / userCount
+		}
+		return 0
+	})
+}
+
+// NewSampledAUC creates an AUC evaluator with sampled negative items. It
+// estimates NewAUC by nNegatives negative items per positive item instead
+// of all unrated items.
+func NewSampledAUC(fullSet DataSet, nNegatives int, seed int64) RankingEvaluator {
+	return newSampledEvaluator(fullSet, nNegatives, seed, func(higher, nNegatives int) float64 {
+		return float64(nNegatives-higher) / float64(nNegatives)
+	})
+}
+
+// NewSampledHitRate creates a HitRate@K evaluator that ranks each positive
+// item among nNegatives sampled negative items (e.g. 1 positive + 100
+// negatives in leave-one-out evaluation).
+func NewSampledHitRate(fullSet DataSet, k int, nNegatives int, seed int64) RankingEvaluator {
+	return newSampledEvaluator(fullSet, nNegatives, seed, func(higher, nNegatives int) float64 {
+		if higher < k {
+			return 1
+		}
+		return 0
+	})
+}
+
+// NewSampledNDCG creates a NDCG@K evaluator that ranks each positive item
+// among nNegatives sampled negative items.
+func NewSampledNDCG(fullSet DataSet, k int, nNegatives int, seed int64) RankingEvaluator {
+	return newSampledEvaluator(fullSet, nNegatives, seed, func(higher, nNegatives int) float64 {
+		if higher < k {
+			return 1 / math.Log2(float64(higher)+2)
+		}
+		return 0
+	})
+}
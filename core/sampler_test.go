@@ -0,0 +1,71 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestUniformSampler(t *testing.T) {
+	// Item 1 is the most popular one.
+	trainSet := NewTrainSet(NewRawSet([]int{1, 2, 3, 4, 4, 4, 5}, []int{1, 1, 1, 1, 2, 3, 4}, []float64{1, 1, 1, 1, 1, 1, 1}))
+	innerUserId := trainSet.ConvertUserID(4)
+	samples1 := NewUniformSampler(trainSet, 0).Sample(innerUserId, 100)
+	samples2 := NewUniformSampler(trainSet, 0).Sample(innerUserId, 100)
+	if !EqualInt(samples1, samples2) {
+		t.Fatal("samples are not reproducible with the same seed")
+	}
+	for _, innerItemId := range samples1 {
+		if trainSet.OuterItemID(innerItemId) != 4 {
+			t.Fatalf("Item %d is not a negative item", trainSet.OuterItemID(innerItemId))
+		}
+	}
+}
+
+func TestPopularitySampler(t *testing.T) {
+	trainSet := NewTrainSet(NewRawSet([]int{1, 2, 3, 4, 4, 4, 5}, []int{1, 1, 1, 1, 2, 3, 4}, []float64{1, 1, 1, 1, 1, 1, 1}))
+	innerUserId := trainSet.ConvertUserID(5)
+	counts := make(map[int]int)
+	for _, innerItemId := range NewPopularitySampler(trainSet, 1, 0).Sample(innerUserId, 1000) {
+		counts[trainSet.OuterItemID(innerItemId)]++
+	}
+	if counts[4] > 0 {
+		t.Fatal("positive item is sampled")
+	}
+	if counts[1] < counts[2] || counts[1] < counts[3] {
+		t.Fatalf("Popular item is not sampled more often: %v", counts)
+	}
+}
+
+func TestHardSampler(t *testing.T) {
+	trainSet := NewTrainSet(NewRawSet([]int{1, 2, 3, 4, 4, 4, 5}, []int{1, 1, 1, 1, 2, 3, 4}, []float64{1, 1, 1, 1, 1, 1, 1}))
+	estimator := &itemEstimator{}
+	estimator.Fit(trainSet)
+	// Item 3 has the highest score among negative items of user 5.
+	samples := NewHardSampler(trainSet, estimator, 50, 0).Sample(trainSet.ConvertUserID(5), 10)
+	for _, innerItemId := range samples {
+		if trainSet.OuterItemID(innerItemId) != 3 {
+			t.Fatalf("Item %d is not the hardest negative item", trainSet.OuterItemID(innerItemId))
+		}
+	}
+}
+
+func TestSampledEvaluators(t *testing.T) {
+	fullSet := NewRawSet([]int{1, 1, 2, 2, 2}, []int{1, 4, 2, 3, 4}, []float64{1, 1, 1, 1, 1})
+	estimator := &itemEstimator{}
+	estimator.Fit(NewTrainSet(fullSet))
+	// Item 4 is ranked above the negative items 2 and 3.
+	testSet := NewRawSet([]int{1}, []int{4}, []float64{1})
+	if auc := NewSampledAUC(fullSet, 10, 0)(estimator, testSet); auc != 1 {
+		t.Fatalf("AUC(%.3f) != %.3f", auc, 1.0)
+	}
+	if hr := NewSampledHitRate(fullSet, 1, 10, 0)(estimator, testSet); hr != 1 {
+		t.Fatalf("HitRate(%.3f) != %.3f", hr, 1.0)
+	}
+	if ndcg := NewSampledNDCG(fullSet, 1, 10, 0)(estimator, testSet); ndcg != 1 {
+		t.Fatalf("NDCG(%.3f) != %.3f", ndcg, 1.0)
+	}
+	// Item 1 is ranked below the negative items 2 and 3.
+	testSet = NewRawSet([]int{1}, []int{1}, []float64{1})
+	if auc := NewSampledAUC(fullSet, 10, 0)(estimator, testSet); auc != 0 {
+		t.Fatalf("AUC(%.3f) != %.3f", auc, 0.0)
+	}
+}
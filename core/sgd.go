@@ -0,0 +1,102 @@
+package core
+
+import (
+	"math/rand"
+	"sync"
+)
+
+const (
+	hogwildSGD = "hogwild"
+	dsgdSGD    = "dsgd"
+)
+
+// sgdSchedule dispatches ratings of a train set to goroutines in each epoch
+// of SGD. Ratings are grouped into strata, which are run one after another.
+// Each stratum holds a list of ratings per goroutine, and goroutines of a
+// stratum run concurrently.
+//
+//	hogwild	- A single stratum. Ratings are sharded into nJobs contiguous
+//			  parts and parameters are updated lock-free (Niu et al.).
+//	dsgd	- Users and items are partitioned into nJobs blocks round-robin
+//			  by inner IDs, and stratum s assigns block (j, (j+s) mod nJobs)
+//			  to goroutine j, so that no two goroutines touch the same user
+//			  or item (Gemulla et al.).
+//
+// Parameters shared by all ratings (e.g. the global bias) are updated
+// lock-free by Hogwild. No block of DSGD owns them, so they are frozen by
+// DSGD with more than one goroutine (see updateShared). If nJobs is 1,
+// ratings are visited in the order of the train set in both modes.
+type sgdSchedule struct {
+	strata [][][]int // stratum -> goroutine -> rating indices
+	shared bool      // Whether parameters shared by all ratings are updated
+}
+
+func newSGDSchedule(trainSet TrainSet, mode string, nJobs int) *sgdSchedule {
+	schedule := new(sgdSchedule)
+	switch mode {
+	case hogwildSGD:
+		shards := make([][]int, nJobs)
+		for j := range shards {
+			begin := trainSet.Length() * j / nJobs
+			end := trainSet.Length() * (j + 1) / nJobs
+			shards[j] = make([]int, 0, end-begin)
+			for i := begin; i < end; i++ {
+				shards[j] = append(shards[j], i)
+			}
+		}
+		schedule.strata = [][][]int{shards}
+		schedule.shared = true
+	case dsgdSGD:
+		schedule.strata = make([][][]int, nJobs)
+		for s := range schedule.strata {
+			schedule.strata[s] = make([][]int, nJobs)
+		}
+		for i := 0; i < trainSet.Length(); i++ {
+			// 轮流分配用户和物品，使各块的评分数量均衡
+			userBlock := trainSet.ConvertUserID(trainSet.Users[i]) % nJobs
+			itemBlock := trainSet.ConvertItemID(trainSet.Items[i]) % nJobs
+			// 物品块 = (用户块 + s) mod nJobs
+			s := (itemBlock - userBlock + nJobs) % nJobs
+			schedule.strata[s][userBlock] = append(schedule.strata[s][userBlock], i)
+		}
+		schedule.shared = nJobs == 1
+	default:
+		panic("unknown parallel SGD: " + mode)
+	}
+	return schedule
+}
+
+// updateShared reports whether parameters shared by all ratings are updated
+// by SGD. If not, they should be fixed to reasonable values (e.g. the global
+// mean) before training.
+func (schedule *sgdSchedule) updateShared() bool {
+	return schedule.shared
+}
+
+// shuffle the order of strata and the order of ratings of each goroutine.
+func (schedule *sgdSchedule) shuffle(rng *rand.Rand) {
+	rng.Shuffle(len(schedule.strata), func(i, j int) {
+		schedule.strata[i], schedule.strata[j] = schedule.strata[j], schedule.strata[i]
+	})
+	for _, stratum := range schedule.strata {
+		shuffleGroups(rng, stratum)
+	}
+}
+
+// run an epoch. The update function is called with the goroutine ID and the
+// index of a rating.
+func (schedule *sgdSchedule) run(update func(jobID, i int)) {
+	for _, stratum := range schedule.strata {
+		var wg sync.WaitGroup
+		wg.Add(len(stratum))
+		for j := range stratum {
+			go func(jobID int) {
+				defer wg.Done()
+				for _, i := range stratum[jobID] {
+					update(jobID, i)
+				}
+			}(j)
+		}
+		wg.Wait()
+	}
+}
@@ -0,0 +1,66 @@
+package core
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestSGDSchedule(t *testing.T) {
+	trainSet := NewTrainSet(LoadDataFromBuiltIn("ml-100k"))
+	// Sequential
+	for _, mode := range []string{hogwildSGD, dsgdSGD} {
+		schedule := newSGDSchedule(trainSet, mode, 1)
+		visited := make([]int, 0, trainSet.Length())
+		schedule.run(func(jobID, i int) {
+			visited = append(visited, i)
+		})
+		for i := range visited {
+			if visited[i] != i {
+				t.Fatalf("%s: the %dth visited rating (%d) != %d", mode, i, visited[i], i)
+			}
+		}
+	}
+	// DSGD strata are conflict-free
+	schedule := newSGDSchedule(trainSet, dsgdSGD, 4)
+	count := 0
+	for _, stratum := range schedule.strata {
+		users, items := make(map[int]int), make(map[int]int)
+		for jobID, indices := range stratum {
+			for _, i := range indices {
+				if j, exist := users[trainSet.Users[i]]; exist && j != jobID {
+					t.Fatalf("User %d is shared by goroutine %d and %d", trainSet.Users[i], j, jobID)
+				}
+				if j, exist := items[trainSet.Items[i]]; exist && j != jobID {
+					t.Fatalf("Item %d is shared by goroutine %d and %d", trainSet.Items[i], j, jobID)
+				}
+				users[trainSet.Users[i]] = jobID
+				items[trainSet.Items[i]] = jobID
+			}
+			count += len(indices)
+		}
+	}
+	if count != trainSet.Length() {
+		t.Fatalf("Number of scheduled ratings (%d) != %d", count, trainSet.Length())
+	}
+	// Shared parameters are frozen by parallel DSGD only
+	if schedule.updateShared() || !newSGDSchedule(trainSet, dsgdSGD, 1).updateShared() ||
+		!newSGDSchedule(trainSet, hogwildSGD, 4).updateShared() {
+		t.Fatal("Shared parameters should only be frozen by parallel DSGD")
+	}
+}
+
+func TestSGDScheduleHogwild(t *testing.T) {
+	trainSet := NewTrainSet(LoadDataFromBuiltIn("ml-100k"))
+	schedule := newSGDSchedule(trainSet, hogwildSGD, 4)
+	visited := make([][]int, 4)
+	schedule.run(func(jobID, i int) {
+		visited[jobID] = append(visited[jobID], i)
+	})
+	all := concatenate(visited...)
+	sort.Ints(all)
+	for i := range all {
+		if all[i] != i {
+			t.Fatalf("Rating %d is not visited once", i)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package core
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Features are side features of a user or an item. Categorical features
+// are encoded as "field=value" (e.g. "genre=Action"), and numeric features
+// are stored by field names (e.g. "age").
+type Features struct {
+	Categorical []string
+	Numeric     map[string]float64
+}
+
+// ItemInfo is the side information of an item.
+type ItemInfo struct {
+	ID          int
+	Title       string
+	ReleaseDate string // Release date in the original format. Empty if unknown.
+	Year        int    // Release year. Zero if unknown.
+	Genres      []string
+}
+
+// Features converts item information to features: genres are categorical
+// and the release year is numeric.
+func (info *ItemInfo) Features() Features {
+	features := Features{Categorical: make([]string, 0, len(info.Genres)), Numeric: make(map[string]float64)}
+	for _, genre := range info.Genres {
+		features.Categorical = append(features.Categorical, "genre="+genre)
+	}
+	if info.Year > 0 {
+		features.Numeric["year"] = float64(info.Year)
+	}
+	return features
+}
+
+// UserInfo is the side information of a user.
+type UserInfo struct {
+	ID         int
+	Age        int // Age (ml-100k) or the lower bound of the age range (ml-1m)
+	Gender     string
+	Occupation string
+	ZipCode    string
+}
+
+// Features converts user information to features: gender and occupation
+// are categorical and the age is numeric. Zip codes are not included.
+func (info *UserInfo) Features() Features {
+	features := Features{Categorical: make([]string, 0, 2), Numeric: make(map[string]float64)}
+	if info.Gender != "" {
+		features.Categorical = append(features.Categorical, "gender="+info.Gender)
+	}
+	if info.Occupation != "" {
+		features.Categorical = append(features.Categorical, "occupation="+info.Occupation)
+	}
+	if info.Age > 0 {
+		features.Numeric["age"] = float64(info.Age)
+	}
+	return features
+}
+
+// SideInfo holds side information of users and items keyed by outer IDs.
+type SideInfo struct {
+	Users map[int]*UserInfo
+	Items map[int]*ItemInfo
+}
+
+func init() {
+	// 参数中的 SideInfo 随模型一起序列化
+	gob.Register(&SideInfo{})
+}
+
+// NewSideInfo creates empty side information.
+func NewSideInfo() *SideInfo {
+	return &SideInfo{
+		Users: make(map[int]*UserInfo),
+		Items: make(map[int]*ItemInfo),
+	}
+}
+
+// UserFeatures returns features of a user. Empty features are returned if
+// the user is unknown.
+func (s *SideInfo) UserFeatures(userId int) Features {
+	if info, exist := s.Users[userId]; exist {
+		return info.Features()
+	}
+	return Features{Numeric: make(map[string]float64)}
+}
+
+// ItemFeatures returns features of an item. Empty features are returned if
+// the item is unknown.
+func (s *SideInfo) ItemFeatures(itemId int) Features {
+	if info, exist := s.Items[itemId]; exist {
+		return info.Features()
+	}
+	return Features{Numeric: make(map[string]float64)}
+}
+
+// InnerUserFeatures joins user features to a train set. The i-th element
+// is the features of the user with inner ID i.
+func (s *SideInfo) InnerUserFeatures(trainSet TrainSet) []Features {
+	features := make([]Features, trainSet.UserCount)
+	for innerUserId, userId := range trainSet.OuterUserIDs {
+		features[innerUserId] = s.UserFeatures(userId)
+	}
+	return features
+}
+
+// InnerItemFeatures joins item features to a train set. The i-th element
+// is the features of the item with inner ID i.
+func (s *SideInfo) InnerItemFeatures(trainSet TrainSet) []Features {
+	features := make([]Features, trainSet.ItemCount)
+	for innerItemId, itemId := range trainSet.OuterItemIDs {
+		features[innerItemId] = s.ItemFeatures(itemId)
+	}
+	return features
+}
+
+/* Loader */
+
+// Side information loaders of built-in data sets.
+var builtInSideInfo = map[string]func(folder string) (*SideInfo, error){
+	"ml-100k": loadML100KSideInfo,
+	"ml-1m":   loadML1MSideInfo,
+}
+
+// LoadSideInfoFromBuiltIn loads side information of a built-in data set,
+// downloads it if not exists. Only ml-100k and ml-1m are supported.
+func LoadSideInfoFromBuiltIn(dataSetName string) (*SideInfo, error) {
+	loader, exist := builtInSideInfo[dataSetName]
+	if !exist {
+		return nil, fmt.Errorf("no side information for data set %s", dataSetName)
+	}
+	dataSet, err := prepareBuiltIn(dataSetName)
+	if err != nil {
+		return nil, err
+	}
+	return loader(filepath.Join(builtInDataFolder, filepath.Dir(dataSet.path)))
+}
+
+// loadML100KSideInfo loads u.item, u.user and u.genre of ml-100k.
+func loadML100KSideInfo(folder string) (*SideInfo, error) {
+	info := NewSideInfo()
+	// Load genres: name|index
+	genres := make([]string, 0)
+	err := readLines(filepath.Join(folder, "u.genre"), "|", func(fields []string) error {
+		genres = append(genres, fields[0])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Load items: id|title|release date|video release date|IMDb URL|genre flags
+	err = readLines(filepath.Join(folder, "u.item"), "|", func(fields []string) error {
+		if len(fields) < 5+len(genres) {
+			return fmt.Errorf("expect %d fields but got %d", 5+len(genres), len(fields))
+		}
+		item, err := parseItemID(fields[0])
+		if err != nil {
+			return err
+		}
+		item.Title = latin1ToUTF8(fields[1])
+		item.ReleaseDate = fields[2]
+		if len(item.ReleaseDate) >= 4 {
+			item.Year, _ = strconv.Atoi(item.ReleaseDate[len(item.ReleaseDate)-4:])
+		}
+		for i, genre := range genres {
+			if fields[5+i] == "1" {
+				item.Genres = append(item.Genres, genre)
+			}
+		}
+		info.Items[item.ID] = item
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Load users: id|age|gender|occupation|zip code
+	err = readLines(filepath.Join(folder, "u.user"), "|", func(fields []string) error {
+		if len(fields) < 5 {
+			return fmt.Errorf("expect %d fields but got %d", 5, len(fields))
+		}
+		return addUser(info, fields[0], fields[1], fields[2], fields[3], fields[4])
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Occupations of ml-1m indexed by codes.
+var ml1MOccupations = []string{
+	"other", "academic/educator", "artist", "clerical/admin", "college/grad student",
+	"customer service", "doctor/health care", "executive/managerial", "farmer", "homemaker",
+	"K-12 student", "lawyer", "programmer", "retired", "sales/marketing",
+	"scientist", "self-employed", "technician/engineer", "tradesman/craftsman", "unemployed",
+	"writer",
+}
+
+// loadML1MSideInfo loads movies.dat and users.dat of ml-1m.
+func loadML1MSideInfo(folder string) (*SideInfo, error) {
+	info := NewSideInfo()
+	// Load items: MovieID::Title::Genres
+	err := readLines(filepath.Join(folder, "movies.dat"), "::", func(fields []string) error {
+		if len(fields) < 3 {
+			return fmt.Errorf("expect %d fields but got %d", 3, len(fields))
+		}
+		item, err := parseItemID(fields[0])
+		if err != nil {
+			return err
+		}
+		item.Title = latin1ToUTF8(fields[1])
+		// Extract year from the title, e.g. "Toy Story (1995)"
+		if n := len(item.Title); n >= 6 && item.Title[n-1] == ')' && item.Title[n-6] == '(' {
+			item.Year, _ = strconv.Atoi(item.Title[n-5 : n-1])
+		}
+		item.Genres = strings.Split(fields[2], "|")
+		info.Items[item.ID] = item
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Load users: UserID::Gender::Age::Occupation::Zip-code
+	err = readLines(filepath.Join(folder, "users.dat"), "::", func(fields []string) error {
+		if len(fields) < 5 {
+			return fmt.Errorf("expect %d fields but got %d", 5, len(fields))
+		}
+		occupation, err := strconv.Atoi(fields[3])
+		if err != nil || occupation < 0 || occupation >= len(ml1MOccupations) {
+			return fmt.Errorf("invalid occupation %q", fields[3])
+		}
+		return addUser(info, fields[0], fields[2], fields[1], ml1MOccupations[occupation], fields[4])
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func parseItemID(text string) (*ItemInfo, error) {
+	itemId, err := strconv.Atoi(text)
+	if err != nil {
+		return nil, err
+	}
+	return &ItemInfo{ID: itemId, Genres: make([]string, 0)}, nil
+}
+
+func addUser(info *SideInfo, userText, ageText, gender, occupation, zipCode string) error {
+	userId, err := strconv.Atoi(userText)
+	if err != nil {
+		return err
+	}
+	age, err := strconv.Atoi(ageText)
+	if err != nil {
+		return err
+	}
+	info.Users[userId] = &UserInfo{ID: userId, Age: age, Gender: gender, Occupation: occupation, ZipCode: zipCode}
+	return nil
+}
+
+// readLines calls handle on the fields of each non-blank line. Errors are
+// reported with file names and line numbers.
+func readLines(fileName string, sep string, handle func(fields []string) error) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if err = handle(strings.Split(line, sep)); err != nil {
+			return &ParseError{FileName: fileName, Line: lineNumber, Text: line, Err: err}
+		}
+	}
+	return scanner.Err()
+}
+
+// latin1ToUTF8 converts a ISO-8859-1 string, used by MovieLens, to UTF-8.
+func latin1ToUTF8(s string) string {
+	runes := make([]rune, len(s))
+	for i := 0; i < len(s); i++ {
+		runes[i] = rune(s[i])
+	}
+	return string(runes)
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestLoadSideInfoML100K(t *testing.T) {
+	info, err := LoadSideInfoFromBuiltIn("ml-100k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Items) != 1682 || len(info.Users) != 943 {
+		t.Fatalf("Number of items and users (%d, %d) != (%d, %d)", len(info.Items), len(info.Users), 1682, 943)
+	}
+	// 1|Toy Story (1995)|01-Jan-1995||...|0|0|0|1|1|1|0|...
+	item := info.Items[1]
+	if item.Title != "Toy Story (1995)" || item.Year != 1995 {
+		t.Fatalf("Item (%s, %d) doesn't match", item.Title, item.Year)
+	}
+	if len(item.Genres) != 3 || item.Genres[0] != "Animation" {
+		t.Fatalf("Genres (%v) don't match", item.Genres)
+	}
+	// 543|Mis\xe9rables, Les (1995)|...
+	if info.Items[543].Title != "Misérables, Les (1995)" {
+		t.Fatalf("Title (%s) isn't decoded", info.Items[543].Title)
+	}
+	// 1|24|M|technician|85711
+	features := info.UserFeatures(1)
+	if features.Numeric["age"] != 24 || len(features.Categorical) != 2 || features.Categorical[0] != "gender=M" {
+		t.Fatalf("User features (%v) don't match", features)
+	}
+	// Join to train set
+	trainSet := NewTrainSet(LoadDataFromBuiltIn("ml-100k"))
+	itemFeatures := info.InnerItemFeatures(trainSet)
+	if len(itemFeatures) != trainSet.ItemCount {
+		t.Fatalf("Number of item features (%d) != %d", len(itemFeatures), trainSet.ItemCount)
+	}
+	innerItemId := trainSet.ConvertItemID(1)
+	if itemFeatures[innerItemId].Categorical[0] != "genre=Animation" {
+		t.Fatalf("Item features (%v) don't match", itemFeatures[innerItemId])
+	}
+}
+
+func TestLoadSideInfoUnknown(t *testing.T) {
+	if _, err := LoadSideInfoFromBuiltIn("ml-20m"); err == nil {
+		t.Fatal("Expect error for data set without side information")
+	}
+}
@@ -6,6 +6,21 @@ import (
 
 type Sim func(SortedIdRatings, SortedIdRatings) float64
 
+// SimBuilder prepares the vectors of users (items) in a train set and the
+// similarity between them, for similarities depending on statistics of the
+// train set (e.g. means or baselines). Vectors are indexed by inner IDs.
+type SimBuilder func(trainSet TrainSet, userBased bool) ([]SortedIdRatings, Sim)
+
+// NewSimBuilder compares rating vectors of users (items) by a similarity.
+func NewSimBuilder(sim Sim) SimBuilder {
+	return func(trainSet TrainSet, userBased bool) ([]SortedIdRatings, Sim) {
+		if userBased {
+			return sorts(trainSet.UserRatings()), sim
+		}
+		return sorts(trainSet.ItemRatings()), sim
+	}
+}
+
 // Cosine 余弦相似度
 func Cosine(a SortedIdRatings, b SortedIdRatings) float64 {
 	m, n, l := .0, .0, .0
@@ -43,27 +58,26 @@ func MSD(a SortedIdRatings, b SortedIdRatings) float64 {
 	return 1.0 / (sum/count + 1.0)
 }
 
-// Pearson 皮尔逊相似度
+// Pearson 皮尔逊相似度，在共同评分的子集上去中心化
 func Pearson(a SortedIdRatings, b SortedIdRatings) float64 {
-	// A 平均值
-	count, sum := .0, .0
+	// 共同评分的平均值
+	count, sumA, sumB := .0, .0, .0
+	ptr := 0
 	for _, ir := range a.data {
-		sum += ir.Rating
-		count += 1
-	}
-	meanA := sum / count
-
-	// B 平均值
-	count, sum = .0, .0
-	for _, ir := range b.data {
-		sum += ir.Rating
-		count += 1
+		for ptr < len(b.data) && b.data[ptr].ID < ir.ID {
+			ptr++
+		}
+		if ptr < len(b.data) && b.data[ptr].ID == ir.ID {
+			sumA += ir.Rating
+			sumB += b.data[ptr].Rating
+			count++
+		}
 	}
-	meanB := sum / count
+	meanA, meanB := sumA/count, sumB/count
 
 	// 去中心化的余弦相似度
 	m, n, l := .0, .0, .0
-	ptr := 0
+	ptr = 0
 	for _, ir := range a.data {
 		for ptr < len(b.data) && b.data[ptr].ID < ir.ID {
 			ptr++
@@ -79,3 +93,110 @@ func Pearson(a SortedIdRatings, b SortedIdRatings) float64 {
 	}
 	return l / (math.Sqrt(m) * math.Sqrt(n))
 }
+
+// Support counts the co-rated IDs of two vectors.
+func Support(a SortedIdRatings, b SortedIdRatings) int {
+	count, ptr := 0, 0
+	for _, ir := range a.data {
+		for ptr < len(b.data) && b.data[ptr].ID < ir.ID {
+			ptr++
+		}
+		if ptr < len(b.data) && b.data[ptr].ID == ir.ID {
+			count++
+		}
+	}
+	return count
+}
+
+// Shrink wraps a similarity function with the minimum support and the
+// shrinkage (Koren). The similarity of two vectors with n co-rated IDs is
+//
+//	sim' = sim * n / (n + shrinkage)
+//
+// if n >= minSupport, otherwise NaN (no similarity). If minSupport <= 1
+// and shrinkage is zero, sim is returned unchanged to save the support
+// counting, and vectors without co-rated IDs are left to sim.
+func Shrink(sim Sim, minSupport int, shrinkage float64) Sim {
+	if minSupport <= 1 && shrinkage == 0 {
+		return sim
+	}
+	return func(a SortedIdRatings, b SortedIdRatings) float64 {
+		n := Support(a, b)
+		if n < minSupport {
+			return math.NaN()
+		}
+		return sim(a, b) * float64(n) / (float64(n) + shrinkage)
+	}
+}
+
+// Jaccard 杰卡德相似度 |A∩B| / |A∪B|，忽略评分值
+func Jaccard(a SortedIdRatings, b SortedIdRatings) float64 {
+	n := float64(Support(a, b))
+	return n / (float64(a.Len()+b.Len()) - n)
+}
+
+// Tanimoto 扩展的杰卡德相似度 a·b / (|a|^2 + |b|^2 - a·b)，对二值评分等价于 Jaccard
+func Tanimoto(a SortedIdRatings, b SortedIdRatings) float64 {
+	l := dot(a, b)
+	return l / (dot(a, a) + dot(b, b) - l)
+}
+
+// AsymmetricCosine 非对称余弦相似度 (Aiolli) a·b / (|a|^{2α} |b|^{2(1-α)})，
+// 范数基于全部评分。α = 0.5 时等于全向量的余弦相似度，不同于只在共同评分上计算范数的 Cosine
+func AsymmetricCosine(alpha float64) Sim {
+	return func(a SortedIdRatings, b SortedIdRatings) float64 {
+		return dot(a, b) / (math.Pow(dot(a, a), alpha) * math.Pow(dot(b, b), 1-alpha))
+	}
+}
+
+// AdjustedCosine 调整余弦相似度，评分减去对方（基于物品时为用户）的平均评分后计算余弦相似度
+func AdjustedCosine(trainSet TrainSet, userBased bool) ([]SortedIdRatings, Sim) {
+	leftRatings, rightRatings := trainSet.UserRatings(), trainSet.ItemRatings()
+	if !userBased {
+		leftRatings, rightRatings = rightRatings, leftRatings
+	}
+	rightMeans := means(rightRatings)
+	return residuals(leftRatings, func(leftID, rightID int) float64 {
+		return rightMeans[rightID]
+	}), Cosine
+}
+
+// PearsonBaseline 基线皮尔逊相似度，评分减去基线预测 μ + b_u + b_i 后计算余弦相似度 (Koren)
+func PearsonBaseline(trainSet TrainSet, userBased bool) ([]SortedIdRatings, Sim) {
+	baseLine := NewBaseLine(nil)
+	baseLine.Fit(trainSet)
+	leftRatings, leftBias, rightBias := trainSet.UserRatings(), baseLine.userBias, baseLine.itemBias
+	if !userBased {
+		leftRatings, leftBias, rightBias = trainSet.ItemRatings(), baseLine.itemBias, baseLine.userBias
+	}
+	return residuals(leftRatings, func(leftID, rightID int) float64 {
+		return baseLine.globalBias + leftBias[leftID] + rightBias[rightID]
+	}), Cosine
+}
+
+// residuals subtracts base scores from rating vectors.
+func residuals(ratings [][]IDRating, base func(leftID, rightID int) float64) []SortedIdRatings {
+	vectors := make([]SortedIdRatings, len(ratings))
+	for leftID, irs := range ratings {
+		residual := make([]IDRating, len(irs))
+		for i, ir := range irs {
+			residual[i] = IDRating{ir.ID, ir.Rating - base(leftID, ir.ID)}
+		}
+		vectors[leftID] = NewSortedIdRatings(residual)
+	}
+	return vectors
+}
+
+// dot computes the inner product of two vectors.
+func dot(a SortedIdRatings, b SortedIdRatings) float64 {
+	sum, ptr := 0.0, 0
+	for _, ir := range a.data {
+		for ptr < len(b.data) && b.data[ptr].ID < ir.ID {
+			ptr++
+		}
+		if ptr < len(b.data) && b.data[ptr].ID == ir.ID {
+			sum += ir.Rating * b.data[ptr].Rating
+		}
+	}
+	return sum
+}
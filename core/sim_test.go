@@ -53,7 +53,136 @@ func TestPearson(t *testing.T) {
 		{2, 2},
 	})
 	sim := Pearson(a, b)
-	if math.Abs(sim) > epsilon {
-		t.Fatal(sim, "!=", 0.0)
+	if math.Abs(sim-1) > epsilon {
+		t.Fatal(sim, "!=", 1.0)
+	}
+}
+
+func TestSupport(t *testing.T) {
+	a := NewSortedIdRatings([]IDRating{
+		{1, 4},
+		{2, 5},
+		{3, 6},
+	})
+	b := NewSortedIdRatings([]IDRating{
+		{0, 0},
+		{1, 1},
+		{2, 2},
+	})
+	if n := Support(a, b); n != 2 {
+		t.Fatal(n, "!=", 2)
+	}
+}
+
+func TestShrink(t *testing.T) {
+	a := NewSortedIdRatings([]IDRating{
+		{1, 4},
+		{2, 5},
+		{3, 6},
+	})
+	b := NewSortedIdRatings([]IDRating{
+		{0, 0},
+		{1, 1},
+		{2, 2},
+	})
+	// sim * n / (n + shrinkage)
+	sim := Shrink(MSD, 1, 2)(a, b)
+	if math.Abs(sim-0.05) > epsilon {
+		t.Fatal(sim, "!=", 0.05)
+	}
+	// Minimum support
+	sim = Shrink(MSD, 3, 0)(a, b)
+	if !math.IsNaN(sim) {
+		t.Fatal(sim, "!=", math.NaN())
+	}
+	// No effect
+	c := NewSortedIdRatings([]IDRating{{4, 1}})
+	if sim = Shrink(Jaccard, 1, 0)(a, c); sim != 0 {
+		t.Fatal(sim, "!=", 0)
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	a := NewSortedIdRatings([]IDRating{
+		{1, 4},
+		{2, 5},
+		{3, 6},
+	})
+	b := NewSortedIdRatings([]IDRating{
+		{0, 0},
+		{1, 1},
+		{2, 2},
+	})
+	sim := Jaccard(a, b)
+	if math.Abs(sim-0.5) > epsilon {
+		t.Fatal(sim, "!=", 0.5)
+	}
+}
+
+func TestTanimoto(t *testing.T) {
+	a := NewSortedIdRatings([]IDRating{
+		{1, 4},
+		{2, 5},
+		{3, 6},
+	})
+	b := NewSortedIdRatings([]IDRating{
+		{0, 0},
+		{1, 1},
+		{2, 2},
+	})
+	sim := Tanimoto(a, b)
+	if math.Abs(sim-0.206) > epsilon {
+		t.Fatal(sim, "!=", 0.206)
+	}
+}
+
+func TestAsymmetricCosine(t *testing.T) {
+	a := NewSortedIdRatings([]IDRating{
+		{1, 4},
+		{2, 5},
+		{3, 6},
+	})
+	b := NewSortedIdRatings([]IDRating{
+		{0, 0},
+		{1, 1},
+		{2, 2},
+	})
+	sim := AsymmetricCosine(0.5)(a, b)
+	if math.Abs(sim-0.714) > epsilon {
+		t.Fatal(sim, "!=", 0.714)
+	}
+	sim = AsymmetricCosine(1)(a, b)
+	if math.Abs(sim-0.182) > epsilon {
+		t.Fatal(sim, "!=", 0.182)
+	}
+}
+
+func TestAdjustedCosine(t *testing.T) {
+	trainSet := NewTrainSet(NewRawSet([]int{1, 1, 2, 2, 3, 3}, []int{10, 20, 10, 20, 10, 20}, []float64{5, 3, 4, 2, 1, 5}))
+	vectors, sim := AdjustedCosine(trainSet, false)
+	ret := sim(vectors[trainSet.ConvertItemID(10)], vectors[trainSet.ConvertItemID(20)])
+	if math.Abs(ret+1) > epsilon {
+		t.Fatal(ret, "!=", -1.0)
+	}
+}
+
+func TestPearsonBaseline(t *testing.T) {
+	trainSet := NewTrainSet(NewRawSet([]int{1, 1, 2, 2, 3, 3}, []int{10, 20, 10, 20, 10, 20}, []float64{5, 3, 4, 2, 1, 5}))
+	baseLine := NewBaseLine(nil)
+	baseLine.Fit(trainSet)
+	vectors, _ := PearsonBaseline(trainSet, true)
+	for innerUserID, vector := range vectors {
+		for _, ir := range vector.data {
+			userID, itemID := trainSet.OuterUserID(innerUserID), trainSet.OuterItemID(ir.ID)
+			rating := 0.0
+			for i := range trainSet.Ratings {
+				if trainSet.Users[i] == userID && trainSet.Items[i] == itemID {
+					rating = trainSet.Ratings[i]
+				}
+			}
+			if residual := rating - baseLine.Predict(userID, itemID); math.Abs(ir.Rating-residual) > epsilon {
+				t.Fatal(ir.Rating, "!=", residual)
+			}
+		}
 	}
 }
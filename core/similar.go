@@ -0,0 +1,69 @@
+package core
+
+import (
+	"gonum.org/v1/gonum/floats"
+	"sort"
+)
+
+// SimilarFinder finds similar items (users) of an item (user) from a
+// fitted model. Outer IDs of the top n similar items (users) are returned
+// with their scores in descending order of score, or all of them if n <= 0.
+// The queried item (user) itself is excluded. Nil is returned if the item
+// (user) is unknown or the model doesn't support the query.
+type SimilarFinder interface {
+	SimilarItems(itemId, n int) []IDScore
+	SimilarUsers(userId, n int) []IDScore
+}
+
+// topIDScores sorts scores and keeps the top n.
+func topIDScores(scores []IDScore, n int) []IDScore {
+	sort.Sort(sortedIDScores(scores))
+	if n > 0 && n < len(scores) {
+		scores = scores[:n]
+	}
+	return scores
+}
+
+// similarFactors ranks vectors by cosine similarities with the vector of an
+// inner ID. Inner IDs are converted to outer IDs by ids.
+func similarFactors(factors [][]float64, ids []int, innerID, n int) []IDScore {
+	return similarVectors(len(factors), func(i int) []float64 {
+		return factors[i]
+	}, ids, innerID, n)
+}
+
+// similarSumFactors ranks vectors a[i] + b[i] like similarFactors. Sums are
+// computed into a shared buffer instead of a new factor matrix.
+func similarSumFactors(a, b [][]float64, ids []int, innerID, n int) []IDScore {
+	if innerID == newID {
+		return nil
+	}
+	sum := make([]float64, len(a[innerID]))
+	return similarVectors(len(a), func(i int) []float64 {
+		return floats.AddTo(sum, a[i], b[i])
+	}, ids, innerID, n)
+}
+
+// similarVectors ranks count vectors by cosine similarities with the vector
+// of an inner ID. The vector returned by vector(i) is only used until the next call.
+func similarVectors(count int, vector func(i int) []float64, ids []int, innerID, n int) []IDScore {
+	if innerID == newID {
+		return nil
+	}
+	query := append([]float64(nil), vector(innerID)...)
+	queryNorm := floats.Norm(query, 2)
+	scores := make([]IDScore, 0, count)
+	for i := 0; i < count; i++ {
+		if i == innerID {
+			continue
+		}
+		factor := vector(i)
+		norm := floats.Norm(factor, 2)
+		// 跳过零向量
+		if norm == 0 || queryNorm == 0 {
+			continue
+		}
+		scores = append(scores, IDScore{ids[i], floats.Dot(query, factor) / (queryNorm * norm)})
+	}
+	return topIDScores(scores, n)
+}
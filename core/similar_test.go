@@ -0,0 +1,119 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+func checkSimilar(t *testing.T, scores []IDScore, expected []int) {
+	if len(scores) != len(expected) {
+		t.Fatalf("Similar IDs %v != %v", scores, expected)
+	}
+	for i := range expected {
+		if scores[i].ID != expected[i] {
+			t.Fatalf("Similar IDs %v != %v", scores, expected)
+		}
+	}
+}
+
+// Models supporting similar queries
+var _ = []SimilarFinder{
+	NewKNN(nil), NewSlopeOne(nil), NewSVD(nil), NewNMF(nil), NewSVDpp(nil),
+	NewTimeSVDpp(nil), NewALS(nil), NewBPR(nil), NewWARP(nil),
+}
+
+func TestSlopeOneSimilar(t *testing.T) {
+	slopeOne := NewSlopeOne(nil)
+	trainSet := NewTrainSet(NewRawSet([]int{1, 1, 1, 2, 2, 3, 4}, []int{10, 20, 30, 10, 20, 10, 40}, []float64{5, 4, 1, 4, 5, 3, 2}))
+	slopeOne.Fit(trainSet)
+	// Items are ranked by the number of co-rating users
+	checkSimilar(t, slopeOne.SimilarItems(10, 0), []int{20, 30})
+	checkSimilar(t, slopeOne.SimilarItems(10, 1), []int{20})
+	if scores := slopeOne.SimilarItems(10, 0); scores[0].Score != 2 || scores[1].Score != 1 {
+		t.Fatalf("Supports %v != [2 1]", scores)
+	}
+	checkSimilar(t, slopeOne.SimilarItems(40, 0), []int{})
+	// Users are ranked by the number of co-rated items
+	checkSimilar(t, slopeOne.SimilarUsers(1, 0), []int{2, 3})
+	if scores := slopeOne.SimilarItems(50, 0); scores != nil {
+		t.Fatalf("Unknown item returns %v", scores)
+	}
+	if scores := slopeOne.SimilarUsers(5, 0); scores != nil {
+		t.Fatalf("Unknown user returns %v", scores)
+	}
+}
+
+func TestKNNSimilar(t *testing.T) {
+	trainSet := NewTrainSet(NewRawSet([]int{1, 1, 1, 2, 2, 3, 4}, []int{10, 20, 30, 10, 20, 10, 40}, []float64{5, 4, 1, 4, 5, 3, 2}))
+	// Item based
+	knn := NewKNN(Parameters{"sim": Cosine, "userBased": false})
+	knn.Fit(trainSet)
+	checkSimilar(t, knn.SimilarItems(10, 0), []int{30, 20})
+	// Neighbors with few supports are dropped
+	knn = NewKNN(Parameters{"sim": Cosine, "userBased": false, "minSupport": 2})
+	knn.Fit(trainSet)
+	itemBasedItems := knn.SimilarItems(10, 0)
+	checkSimilar(t, itemBasedItems, []int{20})
+	itemBasedUsers := knn.SimilarUsers(2, 0)
+	// User based
+	knn = NewKNN(Parameters{"sim": Cosine, "userBased": true, "minSupport": 2})
+	knn.Fit(trainSet)
+	checkSimilar(t, knn.SimilarUsers(2, 0), []int{1})
+	// The other orientation is computed on demand
+	checkSimilar(t, itemBasedUsers, []int{1})
+	userBasedItems := knn.SimilarItems(10, 0)
+	checkSimilar(t, userBasedItems, []int{20})
+	if userBasedItems[0] != itemBasedItems[0] {
+		t.Fatalf("Similar items %v != %v", userBasedItems, itemBasedItems)
+	}
+	if scores := knn.SimilarItems(50, 0); scores != nil {
+		t.Fatalf("Unknown item returns %v", scores)
+	}
+	if scores := knn.SimilarUsers(5, 0); scores != nil {
+		t.Fatalf("Unknown user returns %v", scores)
+	}
+}
+
+func TestFactorSimilar(t *testing.T) {
+	svd := NewSVD(Parameters{"seed": 1})
+	trainSet := NewTrainSet(NewRawSet([]int{1, 1, 1, 2, 2, 3, 4}, []int{10, 20, 30, 10, 20, 10, 40}, []float64{5, 4, 1, 4, 5, 3, 2}))
+	svd.Fit(trainSet)
+	items := svd.SimilarItems(10, 2)
+	if len(items) != 2 {
+		t.Fatalf("Number of similar items (%d) != %d", len(items), 2)
+	}
+	for i, item := range items {
+		if item.ID == 10 {
+			t.Fatal("The queried item is returned")
+		}
+		if item.Score < -1-1e-9 || item.Score > 1+1e-9 {
+			t.Fatalf("Cosine similarity %v is out of range", item.Score)
+		}
+		if i > 0 && items[i-1].Score < item.Score {
+			t.Fatalf("Items are not ranked: %v", items)
+		}
+	}
+	if users := svd.SimilarUsers(1, 0); len(users) != 3 {
+		t.Fatalf("Number of similar users (%d) != %d", len(users), 3)
+	}
+	if items := svd.SimilarItems(50, 0); items != nil {
+		t.Fatalf("Unknown item returns %v", items)
+	}
+}
+
+func TestSVDPPSimilarUsers(t *testing.T) {
+	trainSet := NewTrainSet(NewRawSet([]int{1, 1, 1, 2, 2, 3, 4}, []int{10, 20, 30, 10, 20, 10, 40}, []float64{5, 4, 1, 4, 5, 3, 2}))
+	svdpp := NewSVDpp(Parameters{"seed": 1})
+	svdpp.Fit(trainSet)
+	// Same as ranking the combined user factors
+	expected := similarFactors(svdpp.GetUserFactors(), trainSet.OuterUserIDs, trainSet.ConvertUserID(1), 0)
+	users := svdpp.SimilarUsers(1, 0)
+	if len(users) != len(expected) {
+		t.Fatalf("Similar users %v != %v", users, expected)
+	}
+	for i := range expected {
+		if users[i].ID != expected[i].ID || math.Abs(users[i].Score-expected[i].Score) > 1e-9 {
+			t.Fatalf("Similar users %v != %v", users, expected)
+		}
+	}
+}
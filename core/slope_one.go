@@ -11,6 +11,7 @@ type SlopeOne struct {
 	userRatings [][]IDRating
 	userMeans   []float64
 	dev         [][]float64
+	itemRatings [][]IDRating
 }
 
 func NewSlopeOne(params Parameters) *SlopeOne {
@@ -51,8 +52,8 @@ func (s *SlopeOne) Fit(trainSet TrainSet) {
 	s.userRatings = trainSet.UserRatings()
 	s.userMeans = means(s.userRatings)
 	s.dev = newZeroMatrix(trainSet.ItemCount, trainSet.ItemCount)
-	itemRatings := trainSet.ItemRatings()
-	sorts(itemRatings)
+	itemRatings := sortedRatings(trainSet.ItemRatings())
+	s.itemRatings = itemRatings
 	// 计算物品偏差矩阵
 	// dev[i][j] 代表i、j物品之间的差值，
 	// dev[i][j] = Σ(rating_i - rating_j) / count
@@ -91,3 +92,48 @@ func (s *SlopeOne) Fit(trainSet TrainSet) {
 	}
 	wg.Wait()
 }
+
+// SimilarItems ranks items by supports of deviations, that is, the number
+// of users who rated both items.
+func (s *SlopeOne) SimilarItems(itemId, n int) []IDScore {
+	innerItemID := s.Data.ConvertItemID(itemId)
+	if innerItemID == newID {
+		return nil
+	}
+	// 统计共同评分的用户数量
+	support := make(map[int]float64)
+	for _, ur := range s.itemRatings[innerItemID] {
+		for _, ir := range s.userRatings[ur.ID] {
+			if ir.ID != innerItemID {
+				support[ir.ID]++
+			}
+		}
+	}
+	scores := make([]IDScore, 0, len(support))
+	for j, count := range support {
+		scores = append(scores, IDScore{s.Data.OuterItemIDs[j], count})
+	}
+	return topIDScores(scores, n)
+}
+
+// SimilarUsers ranks users by the number of items rated by both users.
+func (s *SlopeOne) SimilarUsers(userId, n int) []IDScore {
+	innerUserID := s.Data.ConvertUserID(userId)
+	if innerUserID == newID {
+		return nil
+	}
+	// 统计共同评分的物品数量
+	support := make(map[int]float64)
+	for _, ir := range s.userRatings[innerUserID] {
+		for _, ur := range s.itemRatings[ir.ID] {
+			if ur.ID != innerUserID {
+				support[ur.ID]++
+			}
+		}
+	}
+	scores := make([]IDScore, 0, len(support))
+	for j, count := range support {
+		scores = append(scores, IDScore{s.Data.OuterUserIDs[j], count})
+	}
+	return topIDScores(scores, n)
+}
@@ -0,0 +1,308 @@
+package core
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"sort"
+)
+
+/* Temporal splitting */
+
+// timeOrder returns the indices of ratings sorted by timestamps. Ratings
+// with the same timestamp keep their order in the data set.
+func (d *DataSet) timeOrder() []int {
+	if !d.HasTimestamps() {
+		panic("data set has no timestamps")
+	}
+	indices := make([]int, d.Length())
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		return d.Timestamps[indices[i]] < d.Timestamps[indices[j]]
+	})
+	return indices
+}
+
+// SplitByTime splits the data set at a time cutoff. Ratings before the
+// cutoff are used for training and the others are used for test.
+func (d *DataSet) SplitByTime(cutoff int64) (TrainSet, DataSet) {
+	if !d.HasTimestamps() {
+		panic("data set has no timestamps")
+	}
+	trainIndex := make([]int, 0)
+	testIndex := make([]int, 0)
+	for i, timestamp := range d.Timestamps {
+		if timestamp < cutoff {
+			trainIndex = append(trainIndex, i)
+		} else {
+			testIndex = append(testIndex, i)
+		}
+	}
+	return NewTrainSet(d.SubSet(trainIndex)), d.SubSet(testIndex)
+}
+
+// SplitByTimeRatio splits the data set in time order. The latest testSize
+// fraction of ratings is used for test.
+func (d *DataSet) SplitByTimeRatio(testSize float64) (TrainSet, DataSet) {
+	order := d.timeOrder()
+	mid := d.Length() - int(float64(d.Length())*testSize)
+	return NewTrainSet(d.SubSet(order[:mid])), d.SubSet(order[mid:])
+}
+
+// SplitLastN holds out the last n ratings of each user for test. Users
+// with no more than n ratings are kept in the training set entirely, so
+// that every test user has training history.
+func (d *DataSet) SplitLastN(n int) (TrainSet, DataSet) {
+	// Group ratings by users in time order
+	userIndices := make(map[int][]int)
+	for _, i := range d.timeOrder() {
+		userIndices[d.Users[i]] = append(userIndices[d.Users[i]], i)
+	}
+	// Mark the last n ratings of each user
+	isTest := make([]bool, d.Length())
+	for _, indices := range userIndices {
+		if len(indices) > n {
+			for _, i := range indices[len(indices)-n:] {
+				isTest[i] = true
+			}
+		}
+	}
+	return splitByMask(*d, isTest)
+}
+
+// SlidingWindow generates k folds by rolling origin. Ratings are sorted by
+// timestamps and cut into k+1 blocks. The i-th fold tests on the (i+1)-th
+// block and trains on the preceding blocks. If window > 0, only the last
+// window blocks before the test block are used for training, otherwise
+// the training set expands with all previous blocks.
+func (d *DataSet) SlidingWindow(k int, window int) ([]TrainSet, []DataSet) {
+	order := d.timeOrder()
+	trainFolds := make([]TrainSet, k)
+	testFolds := make([]DataSet, k)
+	// The beginning of each block
+	bounds := make([]int, k+2)
+	for i := range bounds {
+		bounds[i] = d.Length() * i / (k + 1)
+	}
+	for i := 0; i < k; i++ {
+		begin := 0
+		if window > 0 && i+1 > window {
+			begin = bounds[i+1-window]
+		}
+		trainFolds[i] = NewTrainSet(d.SubSet(order[begin:bounds[i+1]]))
+		testFolds[i] = d.SubSet(order[bounds[i+1]:bounds[i+2]])
+	}
+	return trainFolds, testFolds
+}
+
+/* Splitter */
+
+// Splitter splits a data set into train folds and test folds.
+type Splitter interface {
+	Split(dataSet DataSet) ([]TrainSet, []DataSet)
+}
+
+// KFoldSplitter splits ratings into k folds randomly.
+type KFoldSplitter struct {
+	K    int
+	Seed int64
+}
+
+func NewKFoldSplitter(k int, seed int64) *KFoldSplitter {
+	return &KFoldSplitter{K: k, Seed: seed}
+}
+
+func (s *KFoldSplitter) Split(dataSet DataSet) ([]TrainSet, []DataSet) {
+	return dataSet.KFold(s.K, s.Seed)
+}
+
+// SlidingWindowSplitter generates folds by rolling origin (see DataSet.SlidingWindow).
+type SlidingWindowSplitter struct {
+	K      int
+	Window int
+}
+
+func NewSlidingWindowSplitter(k int, window int) *SlidingWindowSplitter {
+	return &SlidingWindowSplitter{K: k, Window: window}
+}
+
+func (s *SlidingWindowSplitter) Split(dataSet DataSet) ([]TrainSet, []DataSet) {
+	return dataSet.SlidingWindow(s.K, s.Window)
+}
+
+// LeaveOneOutSplitter holds out one random rating of each user for test.
+// Users with a single rating are kept in the training set.
+type LeaveOneOutSplitter struct {
+	Seed int64
+}
+
+func NewLeaveOneOutSplitter(seed int64) *LeaveOneOutSplitter {
+	return &LeaveOneOutSplitter{Seed: seed}
+}
+
+func (s *LeaveOneOutSplitter) Split(dataSet DataSet) ([]TrainSet, []DataSet) {
+	rng := rand.New(rand.NewSource(s.Seed))
+	isTest := make([]bool, dataSet.Length())
+	for _, indices := range userIndices(dataSet) {
+		if len(indices) > 1 {
+			isTest[indices[rng.Intn(len(indices))]] = true
+		}
+	}
+	trainSet, testSet := splitByMask(dataSet, isTest)
+	return []TrainSet{trainSet}, []DataSet{testSet}
+}
+
+// UserRatioSplitter holds out a testSize fraction of ratings of each user
+// for test. At least one rating of each user is kept for training.
+type UserRatioSplitter struct {
+	TestSize float64
+	Seed     int64
+}
+
+func NewUserRatioSplitter(testSize float64, seed int64) *UserRatioSplitter {
+	return &UserRatioSplitter{TestSize: testSize, Seed: seed}
+}
+
+func (s *UserRatioSplitter) Split(dataSet DataSet) ([]TrainSet, []DataSet) {
+	rng := rand.New(rand.NewSource(s.Seed))
+	isTest := make([]bool, dataSet.Length())
+	for _, indices := range userIndices(dataSet) {
+		nTest := int(float64(len(indices)) * s.TestSize)
+		if nTest >= len(indices) {
+			nTest = len(indices) - 1
+		}
+		for _, j := range rng.Perm(len(indices))[:nTest] {
+			isTest[indices[j]] = true
+		}
+	}
+	trainSet, testSet := splitByMask(dataSet, isTest)
+	return []TrainSet{trainSet}, []DataSet{testSet}
+}
+
+// UserKFoldSplitter splits ratings of each user into k folds, so that
+// every test user appears in the training set of the same fold as long
+// as the user has more than one rating.
+type UserKFoldSplitter struct {
+	K    int
+	Seed int64
+}
+
+func NewUserKFoldSplitter(k int, seed int64) *UserKFoldSplitter {
+	return &UserKFoldSplitter{K: k, Seed: seed}
+}
+
+func (s *UserKFoldSplitter) Split(dataSet DataSet) ([]TrainSet, []DataSet) {
+	rng := rand.New(rand.NewSource(s.Seed))
+	// Assign each rating to a fold
+	folds := make([]int, dataSet.Length())
+	for _, indices := range userIndices(dataSet) {
+		offset := rng.Intn(s.K)
+		for i, j := range rng.Perm(len(indices)) {
+			folds[indices[j]] = (i + offset) % s.K
+		}
+	}
+	trainFolds := make([]TrainSet, s.K)
+	testFolds := make([]DataSet, s.K)
+	isTest := make([]bool, dataSet.Length())
+	for k := 0; k < s.K; k++ {
+		for i := range folds {
+			isTest[i] = folds[i] == k
+		}
+		trainFolds[k], testFolds[k] = splitByMask(dataSet, isTest)
+	}
+	return trainFolds, testFolds
+}
+
+// userIndices groups indices of ratings by users. Users are ordered by IDs.
+func userIndices(dataSet DataSet) [][]int {
+	groups := make(map[int][]int)
+	for i, userId := range dataSet.Users {
+		groups[userId] = append(groups[userId], i)
+	}
+	users := make([]int, 0, len(groups))
+	for userId := range groups {
+		users = append(users, userId)
+	}
+	sort.Ints(users)
+	ret := make([][]int, len(users))
+	for i, userId := range users {
+		ret[i] = groups[userId]
+	}
+	return ret
+}
+
+// splitByMask splits a data set into a train set and a test set by marks.
+func splitByMask(dataSet DataSet, isTest []bool) (TrainSet, DataSet) {
+	trainIndex := make([]int, 0)
+	testIndex := make([]int, 0)
+	for i := range isTest {
+		if isTest[i] {
+			testIndex = append(testIndex, i)
+		} else {
+			trainIndex = append(trainIndex, i)
+		}
+	}
+	return NewTrainSet(dataSet.SubSet(trainIndex)), dataSet.SubSet(testIndex)
+}
+
+// PredefinedSplitter returns predefined folds (e.g. u1.base/u1.test ...
+// u5.base/u5.test of ml-100k) so that results are comparable to the
+// literature. The data set passed to Split is ignored.
+type PredefinedSplitter struct {
+	TrainFolds []TrainSet
+	TestFolds  []DataSet
+}
+
+// NewPredefinedSplitter loads folds from pairs of train and test files.
+func NewPredefinedSplitter(trainFiles, testFiles []string, sep string) (*PredefinedSplitter, error) {
+	if len(trainFiles) != len(testFiles) {
+		return nil, fmt.Errorf("%d train files don't match %d test files", len(trainFiles), len(testFiles))
+	}
+	splitter := &PredefinedSplitter{
+		TrainFolds: make([]TrainSet, len(trainFiles)),
+		TestFolds:  make([]DataSet, len(testFiles)),
+	}
+	for i := range trainFiles {
+		trainSet, _, err := TryLoadDataFromFile(trainFiles[i], sep, true)
+		if err != nil {
+			return nil, err
+		}
+		testSet, _, err := TryLoadDataFromFile(testFiles[i], sep, true)
+		if err != nil {
+			return nil, err
+		}
+		splitter.TrainFolds[i] = NewTrainSet(trainSet)
+		splitter.TestFolds[i] = testSet
+	}
+	return splitter, nil
+}
+
+// NewBuiltInSplitter loads predefined folds of a built-in data set. Each
+// fold is a pair of <name>.base and <name>.test in the folder of the data
+// set. Folds u1 to u5 are used if no fold is given, e.g.
+//
+//	NewBuiltInSplitter("ml-100k")            // u1 ... u5
+//	NewBuiltInSplitter("ml-100k", "ua", "ub") // ua, ub
+func NewBuiltInSplitter(dataSetName string, folds ...string) (*PredefinedSplitter, error) {
+	dataSet, err := prepareBuiltIn(dataSetName)
+	if err != nil {
+		return nil, err
+	}
+	if len(folds) == 0 {
+		folds = []string{"u1", "u2", "u3", "u4", "u5"}
+	}
+	folder := filepath.Join(builtInDataFolder, filepath.Dir(dataSet.path))
+	trainFiles := make([]string, len(folds))
+	testFiles := make([]string, len(folds))
+	for i, fold := range folds {
+		trainFiles[i] = filepath.Join(folder, fold+".base")
+		testFiles[i] = filepath.Join(folder, fold+".test")
+	}
+	return NewPredefinedSplitter(trainFiles, testFiles, dataSet.sep)
+}
+
+func (s *PredefinedSplitter) Split(dataSet DataSet) ([]TrainSet, []DataSet) {
+	return s.TrainFolds, s.TestFolds
+}
@@ -0,0 +1,152 @@
+package core
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestSplitByTime(t *testing.T) {
+	data := NewTimedRawSet([]int{1, 1, 1, 2, 2, 3}, []int{1, 2, 3, 1, 2, 3}, []float64{1, 2, 3, 4, 5, 1}, []int64{30, 10, 50, 20, 60, 40})
+	trainSet, testSet := data.SplitByTime(40)
+	for _, timestamp := range trainSet.Timestamps {
+		if timestamp >= 40 {
+			t.Fatalf("Timestamp (%d) in train set >= %d", timestamp, 40)
+		}
+	}
+	if !EqualInt(testSet.Items, []int{3, 2, 3}) {
+		t.Fatalf("Test items (%v) don't match", testSet.Items)
+	}
+}
+
+func TestSplitByTimeRatio(t *testing.T) {
+	data := NewTimedRawSet([]int{1, 1, 1, 2, 2, 3}, []int{1, 2, 3, 1, 2, 3}, []float64{1, 2, 3, 4, 5, 1}, []int64{30, 10, 50, 20, 60, 40})
+	trainSet, testSet := data.SplitByTimeRatio(0.5)
+	if trainSet.Length() != 3 || testSet.Length() != 3 {
+		t.Fatalf("Split size (%d, %d) != (%d, %d)", trainSet.Length(), testSet.Length(), 3, 3)
+	}
+	if !EqualInt(testSet.Users, []int{3, 1, 2}) {
+		t.Fatalf("Test users (%v) don't match", testSet.Users)
+	}
+}
+
+func TestSplitLastN(t *testing.T) {
+	data := NewTimedRawSet([]int{1, 1, 1, 2, 2, 3}, []int{1, 2, 3, 1, 2, 3}, []float64{1, 2, 3, 4, 5, 1}, []int64{30, 10, 50, 20, 60, 40})
+	trainSet, testSet := data.SplitLastN(1)
+	if !EqualInt(testSet.Users, []int{1, 2}) || !EqualInt(testSet.Items, []int{3, 2}) {
+		t.Fatalf("Test set (%v, %v) doesn't match", testSet.Users, testSet.Items)
+	}
+	// User 3 has only one rating, which is kept for training.
+	if trainSet.ConvertUserID(3) == newID {
+		t.Fatal("User 3 is missing in the train set")
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	data := NewTimedRawSet([]int{1, 1, 1, 2, 2, 3}, []int{1, 2, 3, 1, 2, 3}, []float64{1, 2, 3, 4, 5, 1}, []int64{30, 10, 50, 20, 60, 40})
+	// Expanding window
+	trainFolds, testFolds := data.SlidingWindow(2, 0)
+	if trainFolds[0].Length() != 2 || trainFolds[1].Length() != 4 {
+		t.Fatalf("Train sizes (%d, %d) != (%d, %d)", trainFolds[0].Length(), trainFolds[1].Length(), 2, 4)
+	}
+	for i := range trainFolds {
+		for _, trainTime := range trainFolds[i].Timestamps {
+			for _, testTime := range testFolds[i].Timestamps {
+				if trainTime >= testTime {
+					t.Fatalf("Train timestamp (%d) >= test timestamp (%d)", trainTime, testTime)
+				}
+			}
+		}
+	}
+	// Fixed window
+	trainFolds, _ = data.SlidingWindow(2, 1)
+	if trainFolds[1].Length() != 2 {
+		t.Fatalf("Train size (%d) != %d", trainFolds[1].Length(), 2)
+	}
+}
+
+func TestCrossValidateFolds(t *testing.T) {
+	data := LoadDataFromBuiltIn("ml-100k")
+	trainFolds, testFolds := data.SlidingWindow(3, 0)
+	results := CrossValidateFolds(NewBaseLine(nil), trainFolds, testFolds, []Evaluator{RMSE}, nil, runtime.NumCPU())
+	if len(results[0].Tests) != 3 {
+		t.Fatalf("Number of folds (%d) != %d", len(results[0].Tests), 3)
+	}
+}
+
+func TestLeaveOneOutSplitter(t *testing.T) {
+	data := LoadDataFromBuiltIn("ml-100k")
+	trainFolds, testFolds := NewLeaveOneOutSplitter(0).Split(data)
+	// Each user has exactly one test rating
+	users := trainFolds[0].UserSet()
+	if testFolds[0].Length() != len(users) {
+		t.Fatalf("Number of test ratings (%d) != %d", testFolds[0].Length(), len(users))
+	}
+	if trainFolds[0].Length()+testFolds[0].Length() != data.Length() {
+		t.Fatal("ratings are lost after splitting")
+	}
+}
+
+func TestUserRatioSplitter(t *testing.T) {
+	data := LoadDataFromBuiltIn("ml-100k")
+	trainFolds, testFolds := NewUserRatioSplitter(0.2, 0).Split(data)
+	// Every test user has training history
+	for _, userId := range testFolds[0].Users {
+		if trainFolds[0].ConvertUserID(userId) == newID {
+			t.Fatalf("User %d has no training history", userId)
+		}
+	}
+	ratio := float64(testFolds[0].Length()) / float64(data.Length())
+	if ratio < 0.15 || ratio > 0.2 {
+		t.Fatalf("Test ratio (%.3f) doesn't match %.3f", ratio, 0.2)
+	}
+}
+
+func TestUserKFoldSplitter(t *testing.T) {
+	data := LoadDataFromBuiltIn("ml-100k")
+	trainFolds, testFolds := NewUserKFoldSplitter(5, 0).Split(data)
+	total := 0
+	for i := range testFolds {
+		total += testFolds[i].Length()
+		if trainFolds[i].Length()+testFolds[i].Length() != data.Length() {
+			t.Fatal("ratings are lost after splitting")
+		}
+		for _, userId := range testFolds[i].Users {
+			if trainFolds[i].ConvertUserID(userId) == newID {
+				t.Fatalf("User %d has no training history", userId)
+			}
+		}
+	}
+	if total != data.Length() {
+		t.Fatalf("Number of test ratings (%d) != %d", total, data.Length())
+	}
+}
+
+func TestBuiltInSplitter(t *testing.T) {
+	// u1 ... u5
+	splitter, err := NewBuiltInSplitter("ml-100k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	trainFolds, testFolds := splitter.Split(DataSet{})
+	if len(trainFolds) != 5 {
+		t.Fatalf("Number of folds (%d) != %d", len(trainFolds), 5)
+	}
+	for i := range trainFolds {
+		if trainFolds[i].Length() != 80000 || testFolds[i].Length() != 20000 {
+			t.Fatalf("Fold size (%d, %d) != (%d, %d)", trainFolds[i].Length(), testFolds[i].Length(), 80000, 20000)
+		}
+	}
+	// ua, ub
+	splitter, err = NewBuiltInSplitter("ml-100k", "ua", "ub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := CrossValidate(NewBaseLine(nil), DataSet{}, []Evaluator{RMSE}, splitter, nil, runtime.NumCPU())
+	if len(results[0].Tests) != 2 {
+		t.Fatalf("Number of folds (%d) != %d", len(results[0].Tests), 2)
+	}
+	// Missing fold
+	if _, err = NewBuiltInSplitter("ml-100k", "u6"); err == nil {
+		t.Fatal("Expect error for missing fold")
+	}
+}
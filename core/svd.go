@@ -1,11 +1,11 @@
 package core
 
 import (
-	"fmt"
 	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
 	"math"
+	"math/rand"
 	"runtime"
-	"sync"
 )
 
 // The famous SVD algorithm, as popularized by Simon Funk during the
@@ -29,6 +29,32 @@ func NewSVD(params Parameters) *SVD {
 	svd.Params = params
 	return svd
 }
+
+// GetUserFactors returns p_u of users.
+func (s *SVD) GetUserFactors() [][]float64 {
+	return s.UserFactor
+}
+
+// GetUserFactor returns p_u of a user by the inner ID.
+func (s *SVD) GetUserFactor(innerUserID int) []float64 {
+	return s.UserFactor[innerUserID]
+}
+
+// GetItemFactors returns q_i of items.
+func (s *SVD) GetItemFactors() [][]float64 {
+	return s.ItemFactor
+}
+
+// SimilarItems finds similar items by cosine similarities of item factors.
+func (s *SVD) SimilarItems(itemId, n int) []IDScore {
+	return similarFactors(s.ItemFactor, s.Data.OuterItemIDs, s.Data.ConvertItemID(itemId), n)
+}
+
+// SimilarUsers finds similar users by cosine similarities of user factors.
+func (s *SVD) SimilarUsers(userId, n int) []IDScore {
+	return similarFactors(s.UserFactor, s.Data.OuterUserIDs, s.Data.ConvertUserID(userId), n)
+}
+
 func (s *SVD) Predict(userID, itemID int) float64 {
 	innerUserID := s.Data.ConvertUserID(userID)
 	innerItemID := s.Data.ConvertItemID(itemID)
@@ -50,7 +76,10 @@ func (s *SVD) Predict(userID, itemID int) float64 {
 	return ret
 }
 
-// Fit a SVD model.
+// Fit a SVD model. Ratings are shuffled in each epoch and could be trained
+// in parallel by Hogwild or DSGD (see sgdSchedule). The global bias is fixed
+// to the global mean by DSGD with more than one goroutine. Results are
+// reproducible for the same seed if nJobs is 1.
 // Parameters:
 //
 //	 reg 		- The regularization parameter of the cost function that is
@@ -60,6 +89,9 @@ func (s *SVD) Predict(userID, itemID int) float64 {
 //	 nEpochs	- The number of iteration of the SGD procedure. Default is 20.
 //	 initMean	- The Means of initial random latent factors. Default is 0.
 //	 initStdDev	- The standard deviation of initial random latent factors. Default is 0.1.
+//	 parallel	- The parallel SGD, "hogwild" or "dsgd". Default is "hogwild".
+//	 nJobs		- The number of goroutines. Default is 1.
+//	 seed		- The random seed of initialization and shuffling. Default is 0.
 func (s *SVD) Fit(trainData TrainSet) {
 	// Setup parameters
 	nFactors := s.Params.GetInt("nFactors", 100)
@@ -69,8 +101,13 @@ func (s *SVD) Fit(trainData TrainSet) {
 	//biased := options.GetBool("biased", true)
 	initMean := s.Params.GetFloat64("initMean", 0)
 	initStdDev := s.Params.GetFloat64("initStdDev", 0.1)
+	parallel := s.Params.GetString("parallel", hogwildSGD)
+	nJobs := max(1, s.Params.GetInt("nJobs", 1))
+	seed := int64(s.Params.GetInt("seed", 0))
+	rng := rand.New(rand.NewSource(seed))
 	// 初始化参数
 	s.Data = trainData
+	s.GlobalBias = 0
 	s.UserFactor = make([][]float64, trainData.UserCount)
 	s.ItemFactor = make([][]float64, trainData.ItemCount)
 
@@ -78,19 +115,26 @@ func (s *SVD) Fit(trainData TrainSet) {
 	s.UserBias = make([]float64, trainData.UserCount)
 
 	for i := range s.UserFactor {
-		s.UserFactor[i] = newNormalVector(nFactors, initMean, initStdDev)
+		s.UserFactor[i] = newNormalVector(rng, nFactors, initMean, initStdDev)
 	}
 	for i := range s.ItemFactor {
-		s.ItemFactor[i] = newNormalVector(nFactors, initMean, initStdDev)
+		s.ItemFactor[i] = newNormalVector(rng, nFactors, initMean, initStdDev)
 	}
 
 	// 创建缓存
-	a := make([]float64, nFactors)
-	b := make([]float64, nFactors)
+	a := newZeroMatrix(nJobs, nFactors)
+	b := newZeroMatrix(nJobs, nFactors)
+	schedule := newSGDSchedule(trainData, parallel, nJobs)
+	// 全局偏置无法并行更新时固定为全局平均值
+	if !schedule.updateShared() {
+		s.GlobalBias = trainData.GlobalMean
+	}
 
 	// 随机梯度下降
 	for epoch := 0; epoch < nEpochs; epoch++ {
-		for i := 0; i < trainData.Length(); i++ {
+		schedule.shuffle(rng)
+		schedule.run(func(jobID, i int) {
+			a, b := a[jobID], b[jobID]
 			userID, itemID, rating := trainData.Index(i)
 			innerUserID := trainData.ConvertUserID(userID)
 			innerItemID := trainData.ConvertItemID(itemID)
@@ -102,8 +146,10 @@ func (s *SVD) Fit(trainData TrainSet) {
 			diff := s.Predict(userID, itemID) - rating
 
 			// 计算各个参数的梯度
-			gradGlobalBias := diff
-			s.GlobalBias -= lr * gradGlobalBias
+			if schedule.updateShared() {
+				gradGlobalBias := diff
+				s.GlobalBias -= lr * gradGlobalBias
+			}
 
 			gradUserBias := diff + reg*userBias
 			s.UserBias[innerUserID] -= lr * gradUserBias
@@ -126,7 +172,7 @@ func (s *SVD) Fit(trainData TrainSet) {
 			floats.Add(a, b)
 			mulConst(lr, a)
 			floats.Sub(s.ItemFactor[innerItemID], a)
-		}
+		})
 	}
 
 }
@@ -155,17 +201,20 @@ func (N *NMF) Predict(userId int, itemId int) float64 {
 //	 nEpochs	- The number of iteration of the SGD procedure. Default is 50.
 //	 initLow	- The lower bound of initial random latent factor. Default is 0.
 //	 initHigh	- The upper bound of initial random latent factor. Default is 1.
+//	 seed		- The random seed of initialization. Default is 0.
 func (N *NMF) Fit(trainSet TrainSet) {
 	nFactors := N.Params.GetInt("nFactors", 15)
 	nEpochs := N.Params.GetInt("nEpochs", 50)
 	initLow := N.Params.GetFloat64("initLow", 0)
 	initHigh := N.Params.GetFloat64("initHigh", 1)
 	reg := N.Params.GetFloat64("reg", 0.06)
+	seed := int64(N.Params.GetInt("seed", 0))
+	rng := rand.New(rand.NewSource(seed))
 	//lr := options.GetFloat64("lr", 0.005)
 	N.Data = trainSet
 	// 初始化参数
-	N.userFactor = newUniformMatrix(trainSet.UserCount, nFactors, initLow, initHigh)
-	N.itemFactor = newUniformMatrix(trainSet.ItemCount, nFactors, initLow, initHigh)
+	N.userFactor = newUniformMatrix(rng, trainSet.UserCount, nFactors, initLow, initHigh)
+	N.itemFactor = newUniformMatrix(rng, trainSet.ItemCount, nFactors, initLow, initHigh)
 
 	// 创建Buffer
 	buffer := make([]float64, nFactors)
@@ -256,178 +305,928 @@ func NewNMF(params Parameters) *NMF {
 	return nmf
 }
 
+// GetUserFactors returns p_u of users.
+func (N *NMF) GetUserFactors() [][]float64 {
+	return N.userFactor
+}
+
+// GetUserFactor returns p_u of a user by the inner ID.
+func (N *NMF) GetUserFactor(innerUserID int) []float64 {
+	return N.userFactor[innerUserID]
+}
+
+// GetItemFactors returns q_i of items.
+func (N *NMF) GetItemFactors() [][]float64 {
+	return N.itemFactor
+}
+
+// SimilarItems finds similar items by cosine similarities of item factors.
+func (N *NMF) SimilarItems(itemId, n int) []IDScore {
+	return similarFactors(N.itemFactor, N.Data.OuterItemIDs, N.Data.ConvertItemID(itemId), n)
+}
+
+// SimilarUsers finds similar users by cosine similarities of user factors.
+func (N *NMF) SimilarUsers(userId, n int) []IDScore {
+	return similarFactors(N.userFactor, N.Data.OuterUserIDs, N.Data.ConvertUserID(userId), n)
+}
+
+// SVDPP is the SVD++ algorithm, an extension of SVD taking into account
+// implicit ratings. The prediction \hat{r}_{ui} is set as:
+//
+//	\hat{r}_{ui} = μ + b_u + b_i + q_i^T(p_u + |N(u)|^{-1/2} Σ_{j∈N(u)} y_j)
+//
+// where N(u) is the set of items rated by user u. If user u is unknown,
+// then the bias b_u and the factors p_u and y_j are assumed to be zero.
+// The same applies for item i with b_i and q_i.
 type SVDPP struct {
 	Base
-	UserRatings [][]IDRating
-	UserFactor  [][]float64
-	ItemFactor  [][]float64
-	ImplFactor  [][]float64 //y_i
-	//cacheFactor map[int][]float64
-	UserBias   []float64
-	ItemBias   []float64
-	GlobalBias float64
+	UserRatings    [][]IDRating // N(u)
+	UserFactor     [][]float64  // p_u
+	ItemFactor     [][]float64  // q_i
+	ImplFactor     [][]float64  // y_i
+	UserImplFactor [][]float64  // |N(u)|^{-1/2} Σ_{j∈N(u)} y_j
+	UserBias       []float64    // b_u
+	ItemBias       []float64    // b_i
+	GlobalBias     float64      // μ
 }
 
-func (pp *SVDPP) ensembleImplFactors(innerUserID int) []float64 {
-	nFactors := pp.Params.GetInt("nFactors", 20)
-	emImpFactor := make([]float64, nFactors)
-	count := 0
-	// 用户交互物品历史存在
-	for _, ir := range pp.UserRatings[innerUserID] {
-		floats.Add(emImpFactor, pp.ImplFactor[ir.ID])
-		count++
-	}
-	divConst(math.Sqrt(float64(count)), emImpFactor)
-	return emImpFactor
+func NewSVDpp(params Parameters) *SVDPP {
+	svdpp := new(SVDPP)
+	svdpp.Params = params
+	return svdpp
+}
+
+// GetUserFactors returns p_u + |N(u)|^{-1/2} Σ_{j∈N(u)} y_j of users.
+func (pp *SVDPP) GetUserFactors() [][]float64 {
+	return addFactors(pp.UserFactor, pp.UserImplFactor)
 }
 
-func (pp *SVDPP) internalPredict(userID, itemID int) (float64, []float64) {
+// GetUserFactor returns p_u + |N(u)|^{-1/2} Σ_{j∈N(u)} y_j of a user by the inner ID.
+func (pp *SVDPP) GetUserFactor(innerUserID int) []float64 {
+	return floats.AddTo(make([]float64, len(pp.UserFactor[innerUserID])),
+		pp.UserFactor[innerUserID], pp.UserImplFactor[innerUserID])
+}
 
-	// convert to inner id
+// GetItemFactors returns q_i of items.
+func (pp *SVDPP) GetItemFactors() [][]float64 {
+	return pp.ItemFactor
+}
+
+// SimilarItems finds similar items by cosine similarities of item factors.
+func (pp *SVDPP) SimilarItems(itemId, n int) []IDScore {
+	return similarFactors(pp.ItemFactor, pp.Data.OuterItemIDs, pp.Data.ConvertItemID(itemId), n)
+}
+
+// SimilarUsers finds similar users by cosine similarities of user factors.
+func (pp *SVDPP) SimilarUsers(userId, n int) []IDScore {
+	return similarSumFactors(pp.UserFactor, pp.UserImplFactor, pp.Data.OuterUserIDs, pp.Data.ConvertUserID(userId), n)
+}
+
+// addFactors adds two factor matrices into a new one.
+func addFactors(a, b [][]float64) [][]float64 {
+	ret := make([][]float64, len(a))
+	for i := range a {
+		ret[i] = append([]float64(nil), a[i]...)
+		floats.Add(ret[i], b[i])
+	}
+	return ret
+}
 
+func (pp *SVDPP) Predict(userID, itemID int) float64 {
 	innerUserID := pp.Data.ConvertUserID(userID)
 	innerItemID := pp.Data.ConvertItemID(itemID)
 	ret := pp.GlobalBias
+	// +b_u
 	if innerUserID != newID {
 		ret += pp.UserBias[innerUserID]
 	}
-
+	// +b_i
 	if innerItemID != newID {
 		ret += pp.ItemBias[innerItemID]
 	}
+	// +q_i^T(p_u + |N(u)|^{-1/2} Σ y_j)
 	if innerItemID != newID && innerUserID != newID {
-		userFactor := pp.UserFactor[innerUserID]
-		itemFactor := pp.ItemFactor[innerItemID]
-		emImpFactor := pp.ensembleImplFactors(innerUserID)
-		tmp := make([]float64, len(itemFactor))
-		floats.Add(tmp, userFactor)
-		floats.Add(tmp, emImpFactor)
-		ret += floats.Dot(tmp, itemFactor)
-		return ret, emImpFactor
+		ret += floats.Dot(pp.UserFactor[innerUserID], pp.ItemFactor[innerItemID])
+		ret += floats.Dot(pp.UserImplFactor[innerUserID], pp.ItemFactor[innerItemID])
 	}
-	return ret, []float64{}
-
-}
-func (pp *SVDPP) Predict(userID, itemID int) float64 {
-	predict, _ := pp.internalPredict(userID, itemID)
-	return predict
+	return ret
 }
 
-func (pp *SVDPP) Fit(trainData TrainSet) {
+// Fit a SVD++ model. Ratings of a user are visited together, so that the
+// implicit factors of the user are summed once and y_j are updated once per
+// user in each epoch, instead of once per rating. Users and ratings of each
+// user are shuffled in each epoch.
+// Parameters:
+//
+//	 reg 		- The regularization parameter of the cost function that is
+//				  optimized. Default is 0.02.
+//	 lr 		- The learning rate of SGD. Default is 0.007.
+//	 nFactors	- The number of latent factors. Default is 20.
+//	 nEpochs	- The number of iteration of the SGD procedure. Default is 20.
+//	 initMean	- The Means of initial random latent factors. Default is 0.
+//	 initStdDev	- The standard deviation of initial random latent factors. Default is 0.01.
+//	 callback	- The callback called after each epoch. Default is nil.
+//	 seed		- The random seed of initialization and shuffling. Default is 0.
+func (pp *SVDPP) Fit(trainSet TrainSet) {
 	nFactors := pp.Params.GetInt("nFactors", 20)
 	nEpochs := pp.Params.GetInt("nEpochs", 20)
 	lr := pp.Params.GetFloat64("lr", 0.007)
 	reg := pp.Params.GetFloat64("reg", 0.02)
 	initMean := pp.Params.GetFloat64("initMean", 0)
-	initStdDev := pp.Params.GetFloat64("initStdDev", 0.1)
-	// nJob并发执行
-	nJobs := pp.Params.GetInt("nJobs", runtime.NumCPU())
+	initStdDev := pp.Params.GetFloat64("initStdDev", 0.01)
+	callback := pp.Params.GetCallback("callback", nil)
+	seed := int64(pp.Params.GetInt("seed", 0))
+	rng := rand.New(rand.NewSource(seed))
 	// 初始化参数
-	pp.Data = trainData
-	pp.UserBias = make([]float64, trainData.UserCount)
-	pp.ItemBias = make([]float64, trainData.ItemCount)
-	pp.UserFactor = make([][]float64, trainData.UserCount)
-	pp.ItemFactor = make([][]float64, trainData.ItemCount)
-	pp.ImplFactor = make([][]float64, trainData.ItemCount)
-	//pp.cacheFactor = make(map[int][]float64)
-
-	for innerUserID := range pp.UserBias {
-		pp.UserFactor[innerUserID] = newNormalVector(nFactors, initMean, initStdDev)
+	pp.Data = trainSet
+	pp.GlobalBias = 0
+	pp.UserBias = make([]float64, trainSet.UserCount)
+	pp.ItemBias = make([]float64, trainSet.ItemCount)
+	pp.UserFactor = make([][]float64, trainSet.UserCount)
+	pp.ItemFactor = make([][]float64, trainSet.ItemCount)
+	pp.ImplFactor = make([][]float64, trainSet.ItemCount)
+	pp.UserImplFactor = newZeroMatrix(trainSet.UserCount, nFactors)
+	for i := range pp.UserFactor {
+		pp.UserFactor[i] = newNormalVector(rng, nFactors, initMean, initStdDev)
 	}
-	for innerItemID := range pp.ItemBias {
-		pp.ItemFactor[innerItemID] = newNormalVector(nFactors, initMean, initStdDev)
-		pp.ImplFactor[innerItemID] = newNormalVector(nFactors, initMean, initStdDev)
+	for i := range pp.ItemFactor {
+		pp.ItemFactor[i] = newNormalVector(rng, nFactors, initMean, initStdDev)
+		pp.ImplFactor[i] = newNormalVector(rng, nFactors, initMean, initStdDev)
 	}
 	// 创建用户历史物品
-	pp.UserRatings = trainData.UserRatings()
+	pp.UserRatings = trainSet.UserRatings()
+	userIndices := groupByUser(trainSet)
+	// 随机梯度下降
+	for epoch := 0; epoch < nEpochs; epoch++ {
+		svdppEpoch(rng, trainSet, userIndices, pp.UserRatings, pp.UserFactor, pp.ItemFactor, pp.ImplFactor,
+			pp.UserImplFactor, lr, reg, func(innerUserID, innerItemID, i int) float64 {
+				userID, itemID, rating := trainSet.Index(i)
+				// 计算差值
+				diff := pp.Predict(userID, itemID) - rating
+				// 更新偏置
+				pp.GlobalBias -= lr * diff
+				pp.UserBias[innerUserID] -= lr * (diff + reg*pp.UserBias[innerUserID])
+				pp.ItemBias[innerItemID] -= lr * (diff + reg*pp.ItemBias[innerItemID])
+				return diff
+			})
+		if callback != nil {
+			callback(epoch+1, nEpochs)
+		}
+	}
+	for innerUserID, irs := range pp.UserRatings {
+		sumImplFactors(pp.UserImplFactor[innerUserID], pp.ImplFactor, irs)
+	}
+}
 
+// groupByUser groups indices of ratings by inner user IDs.
+func groupByUser(trainSet TrainSet) [][]int {
+	userIndices := make([][]int, trainSet.UserCount)
+	for i, userID := range trainSet.Users {
+		innerUserID := trainSet.ConvertUserID(userID)
+		userIndices[innerUserID] = append(userIndices[innerUserID], i)
+	}
+	return userIndices
+}
+
+// svdppEpoch runs an epoch of the batched SGD shared by SVD++ and
+// timeSVD++. Users are visited in random order and ratings of a user are
+// visited together, so that |N(u)|^{-1/2} Σ y_j is computed once per user
+// and implicit factors y_j are updated once per user by the accumulated
+// gradient. For each rating, step computes the error and updates biases,
+// and then factors are updated.
+func svdppEpoch(rng *rand.Rand, trainSet TrainSet, userIndices [][]int, userRatings [][]IDRating,
+	userFactors, itemFactors, implFactors, userImplFactors [][]float64, lr, reg float64,
+	step func(innerUserID, innerItemID, i int) float64) {
+	nFactors := 0
+	if len(itemFactors) > 0 {
+		nFactors = len(itemFactors[0])
+	}
 	// 创建缓存
-	a := make([]float64, nFactors)
-	b := make([]float64, nFactors)
+	userFactor := make([]float64, nFactors)
+	gradImpl := make([]float64, nFactors)
+	shuffleGroups(rng, userIndices)
+	for _, innerUserID := range rng.Perm(trainSet.UserCount) {
+		implFactor := userImplFactors[innerUserID]
+		sumImplFactors(implFactor, implFactors, userRatings[innerUserID])
+		norm := 1 / math.Sqrt(float64(len(userRatings[innerUserID])))
+		resetZeroVector(gradImpl)
+		for _, i := range userIndices[innerUserID] {
+			innerItemID := trainSet.ConvertItemID(trainSet.Items[i])
+			itemFactor := itemFactors[innerItemID]
+			diff := step(innerUserID, innerItemID, i)
+			// 累积隐因子梯度
+			floats.AddScaled(gradImpl, diff*norm, itemFactor)
+			// 更新用户因子: p_u -= lr * (diff * q_i + reg * p_u)
+			copy(userFactor, userFactors[innerUserID])
+			floats.AddScaled(userFactors[innerUserID], -lr*reg, userFactor)
+			floats.AddScaled(userFactors[innerUserID], -lr*diff, itemFactor)
+			// 更新物品因子: q_i -= lr * (diff * (p_u + |N(u)|^{-1/2} Σ y_j) + reg * q_i)
+			floats.AddScaled(itemFactor, -lr*reg, itemFactor)
+			floats.AddScaled(itemFactor, -lr*diff, userFactor)
+			floats.AddScaled(itemFactor, -lr*diff, implFactor)
+		}
+		// 更新隐因子: y_j -= lr * (Σ diff * |N(u)|^{-1/2} q_i + reg * y_j)
+		for _, ir := range userRatings[innerUserID] {
+			floats.AddScaled(implFactors[ir.ID], -lr*reg, implFactors[ir.ID])
+			floats.AddScaled(implFactors[ir.ID], -lr, gradImpl)
+		}
+	}
+}
+
+// sumImplFactors computes |N(u)|^{-1/2} Σ_{j∈N(u)} y_j into dst.
+func sumImplFactors(dst []float64, implFactor [][]float64, irs []IDRating) {
+	resetZeroVector(dst)
+	for _, ir := range irs {
+		floats.Add(dst, implFactor[ir.ID])
+	}
+	divConst(math.Sqrt(float64(len(irs))), dst)
+}
+
+// secondsPerDay converts Unix timestamps to days.
+const secondsPerDay = 24 * 60 * 60
+
+// TimeSVDPP is the timeSVD++ algorithm proposed by Koren in the Netflix
+// Prize, which extends SVD++ by temporal dynamics of biases. The prediction
+// of user u to item i at day t is set as:
+//
+//	\hat{r}_{ui}(t) = μ + b_u + α_u dev_u(t) + b_{u,t} + b_i + b_{i,Bin(t)} + q_i^T(p_u + |N(u)|^{-1/2} Σ_{j∈N(u)} y_j)
+//
+// where dev_u(t) = sign(t - t_u)|t - t_u|^β is the drift from the mean
+// rating day t_u of user u, b_{u,t} is the bias of user u at day t and
+// b_{i,Bin(t)} is the bias of item i in the time bin of day t. If the
+// train set has no timestamps, all ratings are assumed to be at day 0.
+type TimeSVDPP struct {
+	Base
+	UserFactor     [][]float64       // p_u
+	ItemFactor     [][]float64       // q_i
+	ImplFactor     [][]float64       // y_i
+	UserImplFactor [][]float64       // |N(u)|^{-1/2} Σ_{j∈N(u)} y_j
+	UserBias       []float64         // b_u
+	UserAlpha      []float64         // α_u
+	UserDayBias    []map[int]float64 // b_{u,t}
+	UserMeanDay    []float64         // t_u
+	ItemBias       []float64         // b_i
+	ItemBinBias    [][]float64       // b_{i,Bin(t)}
+	GlobalBias     float64           // μ
+	MinDay         int               // The first day of the train set
+	MaxDay         int               // The last day of the train set
+	BinSize        int               // The number of days in a time bin
+	Beta           float64           // β
+}
+
+func NewTimeSVDpp(params Parameters) *TimeSVDPP {
+	tpp := new(TimeSVDPP)
+	tpp.Params = params
+	return tpp
+}
+
+// GetUserFactors returns p_u + |N(u)|^{-1/2} Σ_{j∈N(u)} y_j of users.
+func (tpp *TimeSVDPP) GetUserFactors() [][]float64 {
+	return addFactors(tpp.UserFactor, tpp.UserImplFactor)
+}
+
+// GetUserFactor returns p_u + |N(u)|^{-1/2} Σ_{j∈N(u)} y_j of a user by the inner ID.
+func (tpp *TimeSVDPP) GetUserFactor(innerUserID int) []float64 {
+	return floats.AddTo(make([]float64, len(tpp.UserFactor[innerUserID])),
+		tpp.UserFactor[innerUserID], tpp.UserImplFactor[innerUserID])
+}
+
+// GetItemFactors returns q_i of items.
+func (tpp *TimeSVDPP) GetItemFactors() [][]float64 {
+	return tpp.ItemFactor
+}
+
+// SimilarItems finds similar items by cosine similarities of item factors.
+func (tpp *TimeSVDPP) SimilarItems(itemId, n int) []IDScore {
+	return similarFactors(tpp.ItemFactor, tpp.Data.OuterItemIDs, tpp.Data.ConvertItemID(itemId), n)
+}
+
+// SimilarUsers finds similar users by cosine similarities of user factors.
+func (tpp *TimeSVDPP) SimilarUsers(userId, n int) []IDScore {
+	return similarSumFactors(tpp.UserFactor, tpp.UserImplFactor, tpp.Data.OuterUserIDs, tpp.Data.ConvertUserID(userId), n)
+}
+
+// Predict the rating of a user to an item at the last day of the train set.
+func (tpp *TimeSVDPP) Predict(userID, itemID int) float64 {
+	return tpp.predictDay(userID, itemID, tpp.MaxDay)
+}
+
+// PredictWithTime predicts the rating of a user to an item at a Unix timestamp.
+func (tpp *TimeSVDPP) PredictWithTime(userID, itemID int, timestamp int64) float64 {
+	return tpp.predictDay(userID, itemID, int(timestamp/secondsPerDay))
+}
+
+// PredictDataSet predicts all ratings in a data set at their timestamps if
+// exist.
+func (tpp *TimeSVDPP) PredictDataSet(dataSet DataSet) []float64 {
+	predictions := make([]float64, dataSet.Length())
+	for i := range predictions {
+		if dataSet.HasTimestamps() {
+			predictions[i] = tpp.PredictWithTime(dataSet.Users[i], dataSet.Items[i], dataSet.Timestamps[i])
+		} else {
+			predictions[i] = tpp.Predict(dataSet.Users[i], dataSet.Items[i])
+		}
+	}
+	return predictions
+}
+
+func (tpp *TimeSVDPP) predictDay(userID, itemID, day int) float64 {
+	innerUserID := tpp.Data.ConvertUserID(userID)
+	innerItemID := tpp.Data.ConvertItemID(itemID)
+	ret := tpp.GlobalBias
+	if innerUserID != newID {
+		ret += tpp.userBias(innerUserID, day)
+	}
+	if innerItemID != newID {
+		ret += tpp.ItemBias[innerItemID] + tpp.ItemBinBias[innerItemID][tpp.bin(day)]
+	}
+	if innerUserID != newID && innerItemID != newID {
+		ret += floats.Dot(tpp.UserFactor[innerUserID], tpp.ItemFactor[innerItemID])
+		ret += floats.Dot(tpp.UserImplFactor[innerUserID], tpp.ItemFactor[innerItemID])
+	}
+	return ret
+}
+
+// userBias computes b_u + α_u dev_u(t) + b_{u,t}.
+func (tpp *TimeSVDPP) userBias(innerUserID, day int) float64 {
+	return tpp.UserBias[innerUserID] + tpp.UserAlpha[innerUserID]*tpp.dev(innerUserID, day) +
+		tpp.UserDayBias[innerUserID][day]
+}
+
+// dev computes dev_u(t) = sign(t - t_u)|t - t_u|^β.
+func (tpp *TimeSVDPP) dev(innerUserID, day int) float64 {
+	diff := float64(day) - tpp.UserMeanDay[innerUserID]
+	if diff < 0 {
+		return -math.Pow(-diff, tpp.Beta)
+	}
+	return math.Pow(diff, tpp.Beta)
+}
+
+// bin computes the time bin of a day. Days out of the train set are put
+// into the first or the last bin.
+func (tpp *TimeSVDPP) bin(day int) int {
+	nBins := len(tpp.ItemBinBias[0])
+	bin := (day - tpp.MinDay) / tpp.BinSize
+	if bin < 0 {
+		return 0
+	} else if bin >= nBins {
+		return nBins - 1
+	}
+	return bin
+}
 
-	// 随即梯度下降算法
-	// 系数常数已经保存在学习率和正则化系数中
+// Fit a timeSVD++ model. Ratings of a user are visited together like SVD++,
+// and users and ratings of each user are shuffled in each epoch.
+// Parameters:
+//
+//	 reg 		- The regularization parameter of the cost function that is
+//				  optimized. Default is 0.02.
+//	 lr 		- The learning rate of SGD. Default is 0.007.
+//	 regAlpha	- The regularization parameter of α_u. Default is 50.
+//	 lrAlpha	- The learning rate of α_u. Default is 0.00001.
+//	 beta		- The exponent of dev_u(t). Default is 0.4.
+//	 nBins		- The number of time bins of item biases. Default is 30.
+//	 nFactors	- The number of latent factors. Default is 20.
+//	 nEpochs	- The number of iteration of the SGD procedure. Default is 20.
+//	 initMean	- The Means of initial random latent factors. Default is 0.
+//	 initStdDev	- The standard deviation of initial random latent factors. Default is 0.01.
+//	 seed		- The random seed of initialization and shuffling. Default is 0.
+func (tpp *TimeSVDPP) Fit(trainSet TrainSet) {
+	reg := tpp.Params.GetFloat64("reg", 0.02)
+	lr := tpp.Params.GetFloat64("lr", 0.007)
+	regAlpha := tpp.Params.GetFloat64("regAlpha", 50)
+	lrAlpha := tpp.Params.GetFloat64("lrAlpha", 0.00001)
+	nBins := tpp.Params.GetInt("nBins", 30)
+	nFactors := tpp.Params.GetInt("nFactors", 20)
+	nEpochs := tpp.Params.GetInt("nEpochs", 20)
+	initMean := tpp.Params.GetFloat64("initMean", 0)
+	initStdDev := tpp.Params.GetFloat64("initStdDev", 0.01)
+	tpp.Beta = tpp.Params.GetFloat64("beta", 0.4)
+	seed := int64(tpp.Params.GetInt("seed", 0))
+	rng := rand.New(rand.NewSource(seed))
+	// 转换时间戳为天
+	days := make([]int, trainSet.Length())
+	if trainSet.HasTimestamps() {
+		for i, timestamp := range trainSet.Timestamps {
+			days[i] = int(timestamp / secondsPerDay)
+		}
+	}
+	tpp.MinDay, tpp.MaxDay = 0, 0
+	if len(days) > 0 {
+		tpp.MinDay, tpp.MaxDay = days[0], days[0]
+	}
+	for _, day := range days {
+		if day < tpp.MinDay {
+			tpp.MinDay = day
+		}
+		if day > tpp.MaxDay {
+			tpp.MaxDay = day
+		}
+	}
+	tpp.BinSize = (tpp.MaxDay-tpp.MinDay)/nBins + 1
+	// 初始化参数
+	tpp.Data = trainSet
+	tpp.GlobalBias = 0
+	tpp.UserBias = make([]float64, trainSet.UserCount)
+	tpp.UserAlpha = make([]float64, trainSet.UserCount)
+	tpp.UserDayBias = make([]map[int]float64, trainSet.UserCount)
+	tpp.UserMeanDay = make([]float64, trainSet.UserCount)
+	tpp.ItemBias = make([]float64, trainSet.ItemCount)
+	tpp.ItemBinBias = newZeroMatrix(trainSet.ItemCount, nBins)
+	tpp.UserFactor = make([][]float64, trainSet.UserCount)
+	tpp.ItemFactor = make([][]float64, trainSet.ItemCount)
+	tpp.ImplFactor = make([][]float64, trainSet.ItemCount)
+	tpp.UserImplFactor = newZeroMatrix(trainSet.UserCount, nFactors)
+	for i := range tpp.UserFactor {
+		tpp.UserFactor[i] = newNormalVector(rng, nFactors, initMean, initStdDev)
+		tpp.UserDayBias[i] = make(map[int]float64)
+	}
+	for i := range tpp.ItemFactor {
+		tpp.ItemFactor[i] = newNormalVector(rng, nFactors, initMean, initStdDev)
+		tpp.ImplFactor[i] = newNormalVector(rng, nFactors, initMean, initStdDev)
+	}
+	// 按用户分组评分并计算平均评分日
+	userIndices := groupByUser(trainSet)
+	for innerUserID, indices := range userIndices {
+		for _, i := range indices {
+			tpp.UserMeanDay[innerUserID] += float64(days[i])
+		}
+		tpp.UserMeanDay[innerUserID] /= float64(len(indices))
+	}
+	userRatings := trainSet.UserRatings()
+	// 随机梯度下降
 	for epoch := 0; epoch < nEpochs; epoch++ {
-		fmt.Printf("第 %d 轮\n", epoch)
-		for i := 0; i < trainData.Length(); i++ {
-			userID, itemID, rating := trainData.Index(i)
+		svdppEpoch(rng, trainSet, userIndices, userRatings, tpp.UserFactor, tpp.ItemFactor, tpp.ImplFactor,
+			tpp.UserImplFactor, lr, reg, func(innerUserID, innerItemID, i int) float64 {
+				itemID, day, rating := trainSet.Items[i], days[i], trainSet.Ratings[i]
+				bin := tpp.bin(day)
+				dev := tpp.dev(innerUserID, day)
+				// 计算差值
+				diff := tpp.predictDay(trainSet.Users[i], itemID, day) - rating
+				// 更新偏置
+				tpp.GlobalBias -= lr * diff
+				tpp.UserBias[innerUserID] -= lr * (diff + reg*tpp.UserBias[innerUserID])
+				tpp.UserAlpha[innerUserID] -= lrAlpha * (diff*dev + regAlpha*tpp.UserAlpha[innerUserID])
+				dayBias := tpp.UserDayBias[innerUserID][day]
+				tpp.UserDayBias[innerUserID][day] = dayBias - lr*(diff+reg*dayBias)
+				tpp.ItemBias[innerItemID] -= lr * (diff + reg*tpp.ItemBias[innerItemID])
+				tpp.ItemBinBias[innerItemID][bin] -= lr * (diff + reg*tpp.ItemBinBias[innerItemID][bin])
+				return diff
+			})
+	}
+	for innerUserID, irs := range userRatings {
+		sumImplFactors(tpp.UserImplFactor[innerUserID], tpp.ImplFactor, irs)
+	}
+}
 
-			innerUserID := trainData.ConvertUserID(userID)
-			innerItemID := trainData.ConvertItemID(itemID)
+// ALS is the weighted alternating least squares for implicit feedback
+// proposed by Hu, Koren and Volinsky. Each rating r_ui is treated as a
+// positive preference p_ui = 1 with confidence c_ui = 1 + α r_ui, and every
+// unobserved pair has p_ui = 0 with confidence 1. The prediction is:
+//
+//	\hat{p}_{ui} = x_u^Ty_i
+//
+// If user u or item i is unknown, the prediction is zero.
+type ALS struct {
+	Base
+	UserFactor [][]float64 // x_u
+	ItemFactor [][]float64 // y_i
+}
 
-			userBias := pp.UserBias[innerUserID]
-			itemBias := pp.ItemBias[innerItemID]
-			userFactor := pp.UserFactor[innerUserID]
-			itemFactor := pp.ItemFactor[innerItemID]
-			// 计算差值
-			pred, emImpFactor := pp.internalPredict(userID, itemID)
-			diff := pred - rating
-			// 更新全局偏置
-			gradGlobalBias := diff
-			pp.GlobalBias -= lr * gradGlobalBias
+func NewALS(params Parameters) *ALS {
+	als := new(ALS)
+	als.Params = params
+	return als
+}
 
-			// 更新 User 偏置
-			gradUserBias := diff + reg*userBias
-			pp.UserBias[innerUserID] -= lr * gradUserBias
+// GetUserFactors returns x_u of users.
+func (als *ALS) GetUserFactors() [][]float64 {
+	return als.UserFactor
+}
 
-			// item  偏置
-			gradItemBias := diff + reg*itemBias
-			pp.ItemBias[innerItemID] -= lr * gradItemBias
+// GetUserFactor returns x_u of a user by the inner ID.
+func (als *ALS) GetUserFactor(innerUserID int) []float64 {
+	return als.UserFactor[innerUserID]
+}
 
-			// user 潜在因子
-			copy(a, itemFactor)
-			mulConst(diff, a)
-			copy(b, userFactor)
-			mulConst(reg, b)
-			floats.Add(a, b)
-			mulConst(lr, a)
-			floats.Sub(pp.UserFactor[innerUserID], a)
+// GetItemFactors returns y_i of items.
+func (als *ALS) GetItemFactors() [][]float64 {
+	return als.ItemFactor
+}
 
-			// item 潜在因子
-			copy(a, userFactor)
-			if len(emImpFactor) > 0 {
-				floats.Add(a, emImpFactor)
+// SimilarItems finds similar items by cosine similarities of item factors.
+func (als *ALS) SimilarItems(itemId, n int) []IDScore {
+	return similarFactors(als.ItemFactor, als.Data.OuterItemIDs, als.Data.ConvertItemID(itemId), n)
+}
+
+// SimilarUsers finds similar users by cosine similarities of user factors.
+func (als *ALS) SimilarUsers(userId, n int) []IDScore {
+	return similarFactors(als.UserFactor, als.Data.OuterUserIDs, als.Data.ConvertUserID(userId), n)
+}
+
+// SupportImplicit reports that ALS could be trained on implicit feedback.
+func (als *ALS) SupportImplicit() bool {
+	return true
+}
+
+func (als *ALS) Predict(userID, itemID int) float64 {
+	innerUserID := als.Data.ConvertUserID(userID)
+	innerItemID := als.Data.ConvertItemID(itemID)
+	if innerUserID != newID && innerItemID != newID {
+		return floats.Dot(als.UserFactor[innerUserID], als.ItemFactor[innerItemID])
+	}
+	return 0
+}
+
+// Fit an ALS model. User factors and item factors are solved alternately,
+// and the least squares blocks of users (items) are solved in parallel.
+// Parameters:
+//
+//	 reg 		- The regularization parameter of the cost function that is
+//				  optimized. Default is 0.06.
+//	 alpha		- The weight of ratings in confidence. Default is 1.
+//	 nFactors	- The number of latent factors. Default is 15.
+//	 nEpochs	- The number of iteration of the ALS procedure. Default is 15.
+//	 initMean	- The Means of initial random latent factors. Default is 0.
+//	 initStdDev	- The standard deviation of initial random latent factors. Default is 0.1.
+//	 nJobs		- The number of goroutines. Default is the number of CPUs.
+//	 seed		- The random seed of initialization. Default is 0.
+func (als *ALS) Fit(trainSet TrainSet) {
+	nFactors := als.Params.GetInt("nFactors", 15)
+	nEpochs := als.Params.GetInt("nEpochs", 15)
+	reg := als.Params.GetFloat64("reg", 0.06)
+	alpha := als.Params.GetFloat64("alpha", 1)
+	initMean := als.Params.GetFloat64("initMean", 0)
+	initStdDev := als.Params.GetFloat64("initStdDev", 0.1)
+	nJobs := als.Params.GetInt("nJobs", runtime.NumCPU())
+	seed := int64(als.Params.GetInt("seed", 0))
+	rng := rand.New(rand.NewSource(seed))
+	confidence := LinearConfidence(alpha)
+	// 初始化参数
+	als.Data = trainSet
+	als.UserFactor = make([][]float64, trainSet.UserCount)
+	als.ItemFactor = make([][]float64, trainSet.ItemCount)
+	for i := range als.UserFactor {
+		als.UserFactor[i] = newNormalVector(rng, nFactors, initMean, initStdDev)
+	}
+	for i := range als.ItemFactor {
+		als.ItemFactor[i] = newNormalVector(rng, nFactors, initMean, initStdDev)
+	}
+	userRatings := trainSet.UserRatings()
+	itemRatings := trainSet.ItemRatings()
+	// 交替最小二乘
+	for epoch := 0; epoch < nEpochs; epoch++ {
+		// 固定物品因子，求解用户因子
+		alsSolve(als.UserFactor, als.ItemFactor, userRatings, nFactors, reg, confidence, nJobs)
+		// 固定用户因子，求解物品因子
+		alsSolve(als.ItemFactor, als.UserFactor, itemRatings, nFactors, reg, confidence, nJobs)
+	}
+}
+
+// alsSolve updates each row x of dst by the least squares block:
+//
+//	x = (Y^TY + Y^T(C - I)Y + λI)^{-1} Y^TCp
+//
+// where Y is fixed, C is the diagonal confidence matrix of the row and p is
+// the preference vector of the row. Y^TY is computed once for all rows. If
+// A isn't positive definite, the block is solved by LU decomposition, and x
+// is set to zero if A is singular.
+func alsSolve(dst [][]float64, fixed [][]float64, ratings [][]IDRating, nFactors int, reg float64,
+	confidence Confidence, nJobs int) {
+	// Y^TY
+	gram := make([]float64, nFactors*nFactors)
+	for _, y := range fixed {
+		for i := 0; i < nFactors; i++ {
+			for j := 0; j < nFactors; j++ {
+				gram[i*nFactors+j] += y[i] * y[j]
 			}
-			mulConst(diff, a)
-			copy(b, itemFactor)
-			mulConst(reg, b)
-			floats.Add(a, b)
-			mulConst(lr, a)
-			floats.Sub(pp.ItemFactor[innerItemID], a)
-			// 隐因子
-			// todo 并行计算粒度太大
-			nRating := len(pp.UserRatings[innerUserID])
-			var wg sync.WaitGroup
-			wg.Add(nJobs)
-			for j := 0; j < nJobs; j++ {
-				go func(jobId int) {
-					low := nRating * jobId / nJobs
-					high := nRating * (jobId + 1) / nJobs
-					a := make([]float64, nFactors)
-					b := make([]float64, nFactors)
-					for i := low; i < high; i++ {
-						implFactor := pp.ImplFactor[pp.UserRatings[innerUserID][i].ID]
-						copy(a, itemFactor)
-						mulConst(diff, a)
-						divConst(math.Sqrt(float64(len(pp.UserRatings[innerUserID]))), a)
-						copy(b, implFactor)
-						mulConst(reg, b)
-						floats.Add(a, b)
-						mulConst(lr, a)
-						floats.Sub(pp.ImplFactor[pp.UserRatings[innerUserID][i].ID], a)
+		}
+	}
+	parallel(len(dst), nJobs, func(begin, end int) {
+		a := make([]float64, nFactors*nFactors)
+		b := make([]float64, nFactors)
+		var chol mat.Cholesky
+		x := mat.NewVecDense(nFactors, nil)
+		for row := begin; row < end; row++ {
+			// A = Y^TY + Y^T(C - I)Y + λI
+			copy(a, gram)
+			resetZeroVector(b)
+			for _, ir := range ratings[row] {
+				y := fixed[ir.ID]
+				c := confidence(ir.Rating)
+				for i := 0; i < nFactors; i++ {
+					for j := 0; j < nFactors; j++ {
+						a[i*nFactors+j] += (c - 1) * y[i] * y[j]
 					}
-					wg.Done()
-				}(j)
+					// b = Y^TCp
+					b[i] += c * y[i]
+				}
 			}
-			wg.Wait()
+			for i := 0; i < nFactors; i++ {
+				a[i*nFactors+i] += reg
+			}
+			// Solve Ax = b
+			if !chol.Factorize(mat.NewSymDense(nFactors, a)) || chol.SolveVecTo(x, mat.NewVecDense(nFactors, b)) != nil {
+				// 非正定时退回 LU 分解
+				err := x.SolveVec(mat.NewDense(nFactors, nFactors, a), mat.NewVecDense(nFactors, b))
+				if _, ok := err.(mat.Condition); err != nil && !ok || !isFinite(x.RawVector().Data) {
+					resetZeroVector(dst[row])
+					continue
+				}
+			}
+			copy(dst[row], x.RawVector().Data)
+		}
+	})
+}
+
+// BPR is the matrix factorization trained by Bayesian Personalized Ranking
+// (Rendle et al.). It learns from triples (u, i, j), where i is an item
+// rated by user u and j is a negative item sampled from items not rated by
+// u, by maximizing ln σ(\hat{x}_{ui} - \hat{x}_{uj}). The score is:
+//
+//	\hat{x}_{ui} = b_i + p_u^Tq_i
+//
+// If user u is unknown, the factors p_u are assumed to be zero. If item i is
+// unknown, the score is zero.
+type BPR struct {
+	Base
+	UserFactor [][]float64 // p_u
+	ItemFactor [][]float64 // q_i
+	ItemBias   []float64   // b_i
+}
+
+func NewBPR(params Parameters) *BPR {
+	bpr := new(BPR)
+	bpr.Params = params
+	return bpr
+}
+
+// GetUserFactors returns p_u of users.
+func (bpr *BPR) GetUserFactors() [][]float64 {
+	return bpr.UserFactor
+}
+
+// GetUserFactor returns p_u of a user by the inner ID.
+func (bpr *BPR) GetUserFactor(innerUserID int) []float64 {
+	return bpr.UserFactor[innerUserID]
+}
+
+// GetItemFactors returns q_i of items.
+func (bpr *BPR) GetItemFactors() [][]float64 {
+	return bpr.ItemFactor
+}
+
+// SimilarItems finds similar items by cosine similarities of item factors.
+func (bpr *BPR) SimilarItems(itemId, n int) []IDScore {
+	return similarFactors(bpr.ItemFactor, bpr.Data.OuterItemIDs, bpr.Data.ConvertItemID(itemId), n)
+}
+
+// SimilarUsers finds similar users by cosine similarities of user factors.
+func (bpr *BPR) SimilarUsers(userId, n int) []IDScore {
+	return similarFactors(bpr.UserFactor, bpr.Data.OuterUserIDs, bpr.Data.ConvertUserID(userId), n)
+}
+
+// SupportImplicit reports that BPR could be trained on implicit feedback.
+func (bpr *BPR) SupportImplicit() bool {
+	return true
+}
+
+func (bpr *BPR) Predict(userID, itemID int) float64 {
+	innerUserID := bpr.Data.ConvertUserID(userID)
+	innerItemID := bpr.Data.ConvertItemID(itemID)
+	if innerItemID == newID {
+		return 0
+	}
+	ret := bpr.ItemBias[innerItemID]
+	if innerUserID != newID {
+		ret += floats.Dot(bpr.UserFactor[innerUserID], bpr.ItemFactor[innerItemID])
+	}
+	return ret
+}
 
+// Fit a BPR model. Each epoch draws as many triples as ratings: a user is
+// drawn uniformly, a positive item is drawn from items rated by the user and
+// a negative item is drawn by a seeded uniform sampler.
+// Parameters:
+//
+//	 reg 		- The regularization parameter of the cost function that is
+//				  optimized. Default is 0.01.
+//	 lr 		- The learning rate of SGD. Default is 0.05.
+//	 nFactors	- The number of latent factors. Default is 10.
+//	 nEpochs	- The number of iteration of the SGD procedure. Default is 100.
+//	 initMean	- The Means of initial random latent factors. Default is 0.
+//	 initStdDev	- The standard deviation of initial random latent factors. Default is 0.1.
+//	 seed		- The random seed of initialization and sampling. Default is 0.
+func (bpr *BPR) Fit(trainSet TrainSet) {
+	nFactors := bpr.Params.GetInt("nFactors", 10)
+	nEpochs := bpr.Params.GetInt("nEpochs", 100)
+	lr := bpr.Params.GetFloat64("lr", 0.05)
+	reg := bpr.Params.GetFloat64("reg", 0.01)
+	initMean := bpr.Params.GetFloat64("initMean", 0)
+	initStdDev := bpr.Params.GetFloat64("initStdDev", 0.1)
+	seed := int64(bpr.Params.GetInt("seed", 0))
+	rng := rand.New(rand.NewSource(seed))
+	// 初始化参数
+	bpr.Data = trainSet
+	bpr.UserFactor = make([][]float64, trainSet.UserCount)
+	bpr.ItemFactor = make([][]float64, trainSet.ItemCount)
+	bpr.ItemBias = make([]float64, trainSet.ItemCount)
+	for i := range bpr.UserFactor {
+		bpr.UserFactor[i] = newNormalVector(rng, nFactors, initMean, initStdDev)
+	}
+	for i := range bpr.ItemFactor {
+		bpr.ItemFactor[i] = newNormalVector(rng, nFactors, initMean, initStdDev)
+	}
+	userRatings := trainSet.UserRatings()
+	sampler := NewUniformSampler(trainSet, seed)
+	// 创建缓存
+	userFactor := make([]float64, nFactors)
+	diff := make([]float64, nFactors)
+	// 空训练集无法采样
+	if trainSet.UserCount == 0 {
+		return
+	}
+	// 随机梯度下降
+	for epoch := 0; epoch < nEpochs; epoch++ {
+		for n := 0; n < trainSet.Length(); n++ {
+			// 采样 (u, i, j)
+			innerUserID := rng.Intn(trainSet.UserCount)
+			irs := userRatings[innerUserID]
+			positive := irs[rng.Intn(len(irs))].ID
+			negatives := sampler.Sample(innerUserID, 1)
+			if len(negatives) == 0 {
+				continue
+			}
+			negative := negatives[0]
+			// x_uij = x_ui - x_uj
+			copy(diff, bpr.ItemFactor[positive])
+			floats.Sub(diff, bpr.ItemFactor[negative])
+			xuij := bpr.ItemBias[positive] - bpr.ItemBias[negative] + floats.Dot(bpr.UserFactor[innerUserID], diff)
+			// 梯度系数 σ(-x_uij)
+			grad := 1 / (1 + math.Exp(xuij))
+			// 更新物品偏置
+			bpr.ItemBias[positive] += lr * (grad - reg*bpr.ItemBias[positive])
+			bpr.ItemBias[negative] += lr * (-grad - reg*bpr.ItemBias[negative])
+			// 更新用户因子: p_u += lr * (grad * (q_i - q_j) - reg * p_u)
+			copy(userFactor, bpr.UserFactor[innerUserID])
+			floats.AddScaled(bpr.UserFactor[innerUserID], -lr*reg, userFactor)
+			floats.AddScaled(bpr.UserFactor[innerUserID], lr*grad, diff)
+			// 更新物品因子: q_i += lr * (grad * p_u - reg * q_i), q_j += lr * (-grad * p_u - reg * q_j)
+			floats.AddScaled(bpr.ItemFactor[positive], -lr*reg, bpr.ItemFactor[positive])
+			floats.AddScaled(bpr.ItemFactor[positive], lr*grad, userFactor)
+			floats.AddScaled(bpr.ItemFactor[negative], -lr*reg, bpr.ItemFactor[negative])
+			floats.AddScaled(bpr.ItemFactor[negative], -lr*grad, userFactor)
 		}
 	}
+}
+
+// WARP is the matrix factorization trained by Weighted Approximate-Rank
+// Pairwise loss (Weston et al., WSABIE). For a rated item i of user u,
+// negative items are sampled until a violating one j is found, that is
+// \hat{x}_{uj} > \hat{x}_{ui} - 1. If it takes N trials, the rank of i is
+// estimated as ⌊(|I|-1)/N⌋ and the update of the hinge loss is scaled by
+//
+//	L(rank) = Σ_{k=1}^{rank} 1/k
+//
+// so that mistakes at the top of the list are punished harder. The score is:
+//
+//	\hat{x}_{ui} = p_u^Tq_i
+//
+// If user u or item i is unknown, the score is zero.
+type WARP struct {
+	Base
+	UserFactor [][]float64 // p_u
+	ItemFactor [][]float64 // q_i
+}
 
+func NewWARP(params Parameters) *WARP {
+	warp := new(WARP)
+	warp.Params = params
+	return warp
 }
 
-func NewSVDpp(params Parameters) *SVDPP {
-	svdpp := new(SVDPP)
-	svdpp.Params = params
-	return svdpp
+// GetUserFactors returns p_u of users.
+func (warp *WARP) GetUserFactors() [][]float64 {
+	return warp.UserFactor
+}
+
+// GetUserFactor returns p_u of a user by the inner ID.
+func (warp *WARP) GetUserFactor(innerUserID int) []float64 {
+	return warp.UserFactor[innerUserID]
+}
+
+// GetItemFactors returns q_i of items.
+func (warp *WARP) GetItemFactors() [][]float64 {
+	return warp.ItemFactor
+}
+
+// SimilarItems finds similar items by cosine similarities of item factors.
+func (warp *WARP) SimilarItems(itemId, n int) []IDScore {
+	return similarFactors(warp.ItemFactor, warp.Data.OuterItemIDs, warp.Data.ConvertItemID(itemId), n)
+}
+
+// SimilarUsers finds similar users by cosine similarities of user factors.
+func (warp *WARP) SimilarUsers(userId, n int) []IDScore {
+	return similarFactors(warp.UserFactor, warp.Data.OuterUserIDs, warp.Data.ConvertUserID(userId), n)
+}
+
+// SupportImplicit reports that WARP could be trained on implicit feedback.
+func (warp *WARP) SupportImplicit() bool {
+	return true
+}
+
+func (warp *WARP) Predict(userID, itemID int) float64 {
+	innerUserID := warp.Data.ConvertUserID(userID)
+	innerItemID := warp.Data.ConvertItemID(itemID)
+	if innerUserID != newID && innerItemID != newID {
+		return floats.Dot(warp.UserFactor[innerUserID], warp.ItemFactor[innerItemID])
+	}
+	return 0
+}
+
+// Fit a WARP model. Each epoch draws as many <user, positive item> pairs as
+// ratings like BPR.
+// Parameters:
+//
+//	 reg 		- The regularization parameter of the cost function that is
+//				  optimized. Default is 0.01.
+//	 lr 		- The learning rate of SGD. Default is 0.005.
+//	 nFactors	- The number of latent factors. Default is 10.
+//	 nEpochs	- The number of iteration of the SGD procedure. Default is 50.
+//	 maxTrials	- The max number of negative items sampled for a positive item. Default is 100.
+//	 initMean	- The Means of initial random latent factors. Default is 0.
+//	 initStdDev	- The standard deviation of initial random latent factors. Default is 0.1.
+//	 seed		- The random seed of initialization and sampling. Default is 0.
+func (warp *WARP) Fit(trainSet TrainSet) {
+	nFactors := warp.Params.GetInt("nFactors", 10)
+	nEpochs := warp.Params.GetInt("nEpochs", 50)
+	lr := warp.Params.GetFloat64("lr", 0.005)
+	reg := warp.Params.GetFloat64("reg", 0.01)
+	maxTrials := warp.Params.GetInt("maxTrials", 100)
+	initMean := warp.Params.GetFloat64("initMean", 0)
+	initStdDev := warp.Params.GetFloat64("initStdDev", 0.1)
+	seed := int64(warp.Params.GetInt("seed", 0))
+	rng := rand.New(rand.NewSource(seed))
+	// 初始化参数
+	warp.Data = trainSet
+	warp.UserFactor = make([][]float64, trainSet.UserCount)
+	warp.ItemFactor = make([][]float64, trainSet.ItemCount)
+	for i := range warp.UserFactor {
+		warp.UserFactor[i] = newNormalVector(rng, nFactors, initMean, initStdDev)
+	}
+	for i := range warp.ItemFactor {
+		warp.ItemFactor[i] = newNormalVector(rng, nFactors, initMean, initStdDev)
+	}
+	// 预先计算 L(k) = Σ_{j=1}^{k} 1/j
+	rankLoss := make([]float64, trainSet.ItemCount)
+	for k := 1; k < len(rankLoss); k++ {
+		rankLoss[k] = rankLoss[k-1] + 1/float64(k)
+	}
+	userRatings := trainSet.UserRatings()
+	sampler := NewUniformSampler(trainSet, seed)
+	// 创建缓存
+	userFactor := make([]float64, nFactors)
+	diff := make([]float64, nFactors)
+	// 空训练集无法采样
+	if trainSet.UserCount == 0 {
+		return
+	}
+	// 随机梯度下降
+	for epoch := 0; epoch < nEpochs; epoch++ {
+		for n := 0; n < trainSet.Length(); n++ {
+			innerUserID := rng.Intn(trainSet.UserCount)
+			irs := userRatings[innerUserID]
+			positive := irs[rng.Intn(len(irs))].ID
+			positiveScore := floats.Dot(warp.UserFactor[innerUserID], warp.ItemFactor[positive])
+			// 采样直到找到违反间隔的负样本
+			negative, trials := newID, 0
+			for trials < maxTrials {
+				trials++
+				negatives := sampler.Sample(innerUserID, 1)
+				if len(negatives) == 0 {
+					break
+				}
+				if floats.Dot(warp.UserFactor[innerUserID], warp.ItemFactor[negatives[0]]) > positiveScore-1 {
+					negative = negatives[0]
+					break
+				}
+			}
+			if negative == newID {
+				continue
+			}
+			// 估计排名并计算权重
+			weight := rankLoss[(trainSet.ItemCount-1)/trials]
+			copy(diff, warp.ItemFactor[positive])
+			floats.Sub(diff, warp.ItemFactor[negative])
+			// 更新用户因子: p_u += lr * (L * (q_i - q_j) - reg * p_u)
+			copy(userFactor, warp.UserFactor[innerUserID])
+			floats.AddScaled(warp.UserFactor[innerUserID], -lr*reg, userFactor)
+			floats.AddScaled(warp.UserFactor[innerUserID], lr*weight, diff)
+			// 更新物品因子: q_i += lr * (L * p_u - reg * q_i), q_j += lr * (-L * p_u - reg * q_j)
+			floats.AddScaled(warp.ItemFactor[positive], -lr*reg, warp.ItemFactor[positive])
+			floats.AddScaled(warp.ItemFactor[positive], lr*weight, userFactor)
+			floats.AddScaled(warp.ItemFactor[negative], -lr*reg, warp.ItemFactor[negative])
+			floats.AddScaled(warp.ItemFactor[negative], -lr*weight, userFactor)
+		}
+	}
 }
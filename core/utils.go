@@ -40,6 +40,23 @@ func selectInt(a []int, indices []int) []int {
 	}
 	return ret
 }
+func selectInt64(a []int64, indices []int) []int64 {
+	ret := make([]int64, len(indices))
+	for i, index := range indices {
+		ret[i] = a[index]
+	}
+	return ret
+}
+
+// shuffleGroups shuffles each group of indices in place.
+func shuffleGroups(rng *rand.Rand, groups [][]int) {
+	for _, group := range groups {
+		rng.Shuffle(len(group), func(i, j int) {
+			group[i], group[j] = group[j], group[i]
+		})
+	}
+}
+
 func unique(a []int) Set {
 	set := make(map[int]interface{})
 	for _, val := range a {
@@ -68,19 +85,19 @@ func divConst(c float64, dst []float64) {
 
 // Vector
 
-func newNormalVector(size int, mean float64, stdDev float64) []float64 {
+func newNormalVector(rng *rand.Rand, size int, mean float64, stdDev float64) []float64 {
 	ret := make([]float64, size)
 	for i := 0; i < len(ret); i++ {
-		ret[i] = rand.NormFloat64()*stdDev + mean
+		ret[i] = rng.NormFloat64()*stdDev + mean
 	}
 	return ret
 }
 
-func newUniformVector(size int, low float64, high float64) []float64 {
+func newUniformVector(rng *rand.Rand, size int, low float64, high float64) []float64 {
 	ret := make([]float64, size)
 	scale := high - low
 	for i := 0; i < len(ret); i++ {
-		ret[i] = rand.Float64()*scale + low
+		ret[i] = rng.Float64()*scale + low
 	}
 	return ret
 }
@@ -90,20 +107,30 @@ func resetZeroVector(a []float64) {
 	}
 }
 
-func newUniformVectorInt(size, low, high int) []int {
+// isFinite checks whether all elements of a vector are finite.
+func isFinite(a []float64) bool {
+	for _, v := range a {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func newUniformVectorInt(rng *rand.Rand, size, low, high int) []int {
 	ret := make([]int, size)
 	scale := high - low
 	for i := 0; i < len(ret); i++ {
-		ret[i] = rand.Intn(scale) + low
+		ret[i] = rng.Intn(scale) + low
 	}
 	return ret
 }
 
 // Matrix
-func newUniformMatrix(row, col int, low, high float64) [][]float64 {
+func newUniformMatrix(rng *rand.Rand, row, col int, low, high float64) [][]float64 {
 	ret := make([][]float64, row)
 	for i := range ret {
-		ret[i] = newUniformVector(col, low, high)
+		ret[i] = newUniformVector(rng, col, low, high)
 	}
 	return ret
 }
@@ -156,25 +183,58 @@ func parallel(nTask int, nJob int, worker func(begin, end int)) {
 	wg.Wait()
 }
 
-// Evaluator
-type Evaluator func(Estimator, DataSet) float64
+// Evaluator scores an estimator on a test set.
+type Evaluator interface {
+	Evaluate(estimator Estimator, testSet DataSet) float64
+	// Maximize reports whether higher scores are better.
+	Maximize() bool
+}
+
+// ErrorEvaluator is an evaluator of prediction errors (e.g. RMSE and MAE),
+// which are minimized.
+type ErrorEvaluator func(Estimator, DataSet) float64
+
+func (evaluator ErrorEvaluator) Evaluate(estimator Estimator, testSet DataSet) float64 {
+	return evaluator(estimator, testSet)
+}
+
+func (evaluator ErrorEvaluator) Maximize() bool {
+	return false
+}
+
+// RankingEvaluator is an evaluator of ranking quality (e.g. AUC and NDCG),
+// which is maximized.
+type RankingEvaluator func(Estimator, DataSet) float64
+
+func (evaluator RankingEvaluator) Evaluate(estimator Estimator, testSet DataSet) float64 {
+	return evaluator(estimator, testSet)
+}
+
+func (evaluator RankingEvaluator) Maximize() bool {
+	return true
+}
+
+// RMSE is the root mean square error of predictions.
+var RMSE ErrorEvaluator = rmse
+
+// MAE is the mean absolute error of predictions.
+var MAE ErrorEvaluator = mae
 
-func RMSE(estimator Estimator, testSet DataSet) float64 {
+func rmse(estimator Estimator, testSet DataSet) float64 {
+	predictions := testSet.Predict(estimator)
 	sum := 0.0
-	for j := 0; j < testSet.Length(); j++ {
-		userId, itemId, rating := testSet.Index(j)
-		prediction := estimator.Predict(userId, itemId)
+	for j, prediction := range predictions {
+		rating := testSet.Ratings[j]
 		sum += (prediction - rating) * (prediction - rating)
 	}
 	return math.Sqrt(sum / float64(testSet.Length()))
 }
 
-func MAE(estimator Estimator, testSet DataSet) float64 {
+func mae(estimator Estimator, testSet DataSet) float64 {
+	predictions := testSet.Predict(estimator)
 	sum := 0.0
-	for j := 0; j < testSet.Length(); j++ {
-		userId, itemId, rating := testSet.Index(j)
-		prediction := estimator.Predict(userId, itemId)
-		sum += math.Abs(prediction - rating)
+	for j, prediction := range predictions {
+		sum += math.Abs(prediction - testSet.Ratings[j])
 	}
 	return sum / float64(testSet.Length())
 }
@@ -4,6 +4,7 @@ import (
 	"gonum.org/v1/gonum/floats"
 	"gonum.org/v1/gonum/stat"
 	"math"
+	"math/rand"
 	"testing"
 )
 
@@ -65,7 +66,7 @@ func TestDivConst(t *testing.T) {
 }
 
 func TestNewNormalVector(t *testing.T) {
-	a := newNormalVector(1000, 1, 2)
+	a := newNormalVector(rand.New(rand.NewSource(0)), 1000, 1, 2)
 	mean := stat.Mean(a, nil)
 	stdDev := stat.StdDev(a, nil)
 	if math.Abs(mean-1) > 0.2 {
@@ -76,7 +77,7 @@ func TestNewNormalVector(t *testing.T) {
 }
 
 func TestNewUniformVector(t *testing.T) {
-	a := newUniformVectorInt(100, 10, 100)
+	a := newUniformVectorInt(rand.New(rand.NewSource(0)), 100, 10, 100)
 	for _, val := range a {
 		if val < 10 {
 			t.Fail()
@@ -1,6 +0,0 @@
-package main
-
-// Learn benchmark
-func main() {
-
-}